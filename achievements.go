@@ -0,0 +1,314 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"time"
+)
+
+const (
+	achievementsFile    = "achievements.json"
+	achievementsVersion = 1
+)
+
+// Achievements are defined in a data file as a list of conditions on counters
+// that are checked at a certain moment during the run.
+type achievementDef struct {
+	ID          string                 `json:"id"`
+	Title       string                 `json:"title"`
+	Description string                 `json:"description"`
+	When        string                 `json:"when"`
+	Conditions  []achievementCondition `json:"conditions"`
+	when        achievementTrigger
+}
+
+type achievementCondition struct {
+	Counter string   `json:"counter"`
+	Scope   string   `json:"scope"`
+	Min     *float64 `json:"min"`
+	Max     *float64 `json:"max"`
+	counter achievementCounter
+	scope   achievementScope
+}
+
+type achievementTrigger int
+
+const (
+	triggerFRAME achievementTrigger = iota
+	triggerLEVELCLEAR
+	triggerGAMEOVER
+)
+
+var achievementTriggers = map[string]achievementTrigger{
+	"frame":       triggerFRAME,
+	"level_clear": triggerLEVELCLEAR,
+	"game_over":   triggerGAMEOVER,
+}
+
+type achievementScope int
+
+const (
+	scopeLEVEL achievementScope = iota
+	scopeRUN
+)
+
+var achievementScopes = map[string]achievementScope{
+	"level": scopeLEVEL,
+	"run":   scopeRUN,
+}
+
+type achievementCounter int
+
+const (
+	counterSCORE achievementCounter = iota
+	counterLEVEL
+	counterTIME
+	counterSHOTS
+	counterHITS
+	counterACCURACY
+	counterASTEROIDS
+	counterDEBRIS
+	counterBOUNCES
+	counterHYPERSPACES
+	counterTHRUSTTIME
+	counterCOMBO
+	counterASTEROIDDEATHS
+	counterDEBRISDEATHS
+	numCounters
+)
+
+var achievementCounters = map[string]achievementCounter{
+	"score":           counterSCORE,
+	"level":           counterLEVEL,
+	"time":            counterTIME,
+	"shots":           counterSHOTS,
+	"hits":            counterHITS,
+	"accuracy":        counterACCURACY,
+	"asteroids":       counterASTEROIDS,
+	"debris":          counterDEBRIS,
+	"bounces":         counterBOUNCES,
+	"hyperspaces":     counterHYPERSPACES,
+	"thrust_time":     counterTHRUSTTIME,
+	"combo":           counterCOMBO,
+	"asteroid_deaths": counterASTEROIDDEATHS,
+	"debris_deaths":   counterDEBRISDEATHS,
+}
+
+// loadAchievementDefs reads and validates the achievement definitions.
+func loadAchievementDefs(fsys fs.FS, filename string) ([]achievementDef, error) {
+	data, err := fs.ReadFile(fsys, filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var defs []achievementDef
+	if err := json.Unmarshal(data, &defs); err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+
+	for i := range defs {
+		d := &defs[i]
+		var ok bool
+		if d.when, ok = achievementTriggers[d.When]; !ok {
+			return nil, fmt.Errorf("%s: %s: unknown trigger %q", filename, d.ID, d.When)
+		}
+
+		for j := range d.Conditions {
+			c := &d.Conditions[j]
+			if c.counter, ok = achievementCounters[c.Counter]; !ok {
+				return nil, fmt.Errorf("%s: %s: unknown counter %q", filename, d.ID, c.Counter)
+			} else if c.scope, ok = achievementScopes[c.Scope]; !ok {
+				return nil, fmt.Errorf("%s: %s: unknown scope %q", filename, d.ID, c.Scope)
+			}
+		}
+	}
+
+	return defs, nil
+}
+
+// achievementStore holds the definitions and remembers which ones are unlocked.
+type achievementStore struct {
+	Defs     []achievementDef
+	Unlocked map[string]time.Time
+}
+
+func (st *achievementStore) IsUnlocked(id string) bool {
+	_, ok := st.Unlocked[id]
+	return ok
+}
+
+// Unlock marks an achievement as unlocked and saves the store.
+func (st *achievementStore) Unlock(id string) {
+	st.Unlocked[id] = time.Now()
+	if err := st.Save(); err != nil {
+		fmt.Println(err)
+	}
+}
+
+type achievementsJSON struct {
+	Version  int                  `json:"version"`
+	Unlocked map[string]time.Time `json:"unlocked"`
+}
+
+// loadAchievementStore reads the unlocked achievements from disk.
+// A missing file is not an error, and an unreadable one yields an empty store and the reason why.
+func loadAchievementStore(defs []achievementDef) (*achievementStore, error) {
+	st := &achievementStore{
+		Defs:     defs,
+		Unlocked: map[string]time.Time{},
+	}
+
+	filename, err := userFilePath(achievementsFile)
+	if err != nil {
+		return st, err
+	}
+
+	data, err := os.ReadFile(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return st, nil
+	} else if err != nil {
+		return st, err
+	}
+
+	var v achievementsJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return st, fmt.Errorf("%s: %v", filename, err)
+	} else if v.Version != achievementsVersion {
+		return st, fmt.Errorf("%s: unsupported version %d", filename, v.Version)
+	}
+
+	for id, t := range v.Unlocked {
+		st.Unlocked[id] = t
+	}
+	return st, nil
+}
+
+func (st *achievementStore) Save() error {
+	filename, err := userFilePath(achievementsFile)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(achievementsJSON{
+		Version:  achievementsVersion,
+		Unlocked: st.Unlocked,
+	}, "", "\t")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filename, data)
+}
+
+// achievementTracker keeps the counters of the current level and run
+// by observing the simulation every frame, and unlocks achievements when their conditions are met.
+type achievementTracker struct {
+	Store    *achievementStore
+	Level    [numCounters]float64
+	Run      [numCounters]float64
+	Unlocked []*achievementDef // unlocked but not yet shown to the player
+}
+
+// NewGame resets the counters of the run.
+func (at *achievementTracker) NewGame() {
+	at.Run = [numCounters]float64{}
+	at.Level = [numCounters]float64{}
+}
+
+// BeginLevel resets the counters of the level.
+func (at *achievementTracker) BeginLevel() {
+	at.Level = [numCounters]float64{}
+}
+
+func (at *achievementTracker) Observe(sim *theSimulation, deltaTime float64) {
+	if at.Store == nil {
+		return
+	}
+
+	var delta [numCounters]float64
+	delta[counterTIME] = deltaTime
+
+	for _, ev := range sim.Events {
+		switch ev.Code {
+		case eventFIRE:
+			delta[counterSHOTS]++
+		case eventHIT:
+			delta[counterHITS]++
+		case eventDESTROYED:
+			if ev.Value&flagASTEROID != 0 {
+				delta[counterASTEROIDS]++
+			} else {
+				delta[counterDEBRIS]++
+			}
+		case eventBOUNCE:
+			delta[counterBOUNCES]++
+		case eventHYPERSPACE:
+			delta[counterHYPERSPACES]++
+		case eventTHRUST:
+			delta[counterTHRUSTTIME] += deltaTime
+		case eventDEATH:
+			if ev.Value&flagASTEROID != 0 {
+				delta[counterASTEROIDDEATHS]++
+			} else {
+				delta[counterDEBRISDEATHS]++
+			}
+		}
+	}
+
+	for _, counters := range []*[numCounters]float64{&at.Level, &at.Run} {
+		for i, d := range delta {
+			counters[i] += d
+		}
+		counters[counterSCORE] = float64(sim.Score)
+		counters[counterLEVEL] = float64(1 + sim.Level)
+		if float64(sim.Multiplier) > counters[counterCOMBO] {
+			counters[counterCOMBO] = float64(sim.Multiplier)
+		}
+		if counters[counterSHOTS] > 0 {
+			counters[counterACCURACY] = 100 * counters[counterHITS] / counters[counterSHOTS]
+		}
+	}
+
+	at.check(triggerFRAME)
+	switch sim.State {
+	case stateNEXTLEVEL:
+		at.check(triggerLEVELCLEAR)
+	case stateGAMEOVER:
+		at.check(triggerGAMEOVER)
+	}
+}
+
+func (at *achievementTracker) check(when achievementTrigger) {
+	for i := range at.Store.Defs {
+		d := &at.Store.Defs[i]
+		if d.when == when && !at.Store.IsUnlocked(d.ID) && at.met(d) {
+			at.Store.Unlock(d.ID)
+			at.Unlocked = append(at.Unlocked, d)
+		}
+	}
+}
+
+func (at *achievementTracker) met(d *achievementDef) bool {
+	for _, c := range d.Conditions {
+		v := at.Level[c.counter]
+		if c.scope == scopeRUN {
+			v = at.Run[c.counter]
+		}
+
+		if c.Min != nil && v < *c.Min {
+			return false
+		} else if c.Max != nil && v > *c.Max {
+			return false
+		}
+	}
+	return true
+}
+
+// PopUnlocked returns the achievements that were unlocked since the last call.
+func (at *achievementTracker) PopUnlocked() []*achievementDef {
+	unlocked := at.Unlocked
+	at.Unlocked = nil
+	return unlocked
+}
@@ -0,0 +1,78 @@
+//go:build !headless
+// +build !headless
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/askeladdk/pancake"
+	"github.com/askeladdk/pancake/graphics"
+	"github.com/askeladdk/pancake/mathx"
+	"github.com/askeladdk/pancake/text"
+)
+
+type achievementsScreen struct {
+	Store      *achievementStore
+	Text       *text.Text
+	Drawer     *tintDrawer
+	Shader     *graphics.ShaderProgram
+	Background staticImage
+	Done       bool
+}
+
+func newAchievementsScreen(res *resources) *achievementsScreen {
+	t := text.NewText(res.Font12)
+	t.Pos = mathx.Vec2{res.Midscreen()[0] - 192, 32}
+	return &achievementsScreen{
+		Store:      res.Achievements,
+		Text:       t,
+		Drawer:     res.Drawer,
+		Shader:     res.Shader,
+		Background: res.Background,
+	}
+}
+
+func (s *achievementsScreen) Begin() {
+	s.Done = false
+	s.Text.Clear()
+
+	var unlocked int
+	for _, d := range s.Store.Defs {
+		if s.Store.IsUnlocked(d.ID) {
+			unlocked++
+		}
+	}
+
+	fmt.Fprintf(s.Text, "Achievements (%d of %d unlocked)\n\n", unlocked, len(s.Store.Defs))
+	for _, d := range s.Store.Defs {
+		mark := " "
+		if s.Store.IsUnlocked(d.ID) {
+			mark = "x"
+		}
+		fmt.Fprintf(s.Text, "[%s] %s\n    %s\n", mark, d.Title, d.Description)
+	}
+	fmt.Fprintf(s.Text, "\nPress any key to return.")
+}
+
+func (s *achievementsScreen) End() {}
+
+func (s *achievementsScreen) Key(ev pancake.KeyEvent) error {
+	s.Done = s.Done || ev.Flags.Pressed()
+	return nil
+}
+
+func (s *achievementsScreen) Frame(ev pancake.FrameEvent) (screenOp, error) {
+	if s.Done {
+		return pop(), nil
+	}
+	return screenOp{}, nil
+}
+
+func (s *achievementsScreen) Draw(ev pancake.DrawEvent) error {
+	s.Shader.Begin()
+	s.Drawer.Draw(s.Background)
+	s.Drawer.Draw(s.Text)
+	s.Shader.End()
+	return nil
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Batches of games are played without a window to compare the balance and performance
+// of the game between changes with numbers. The games are reproducible because
+// the seeds and the input are, so two runs on the same commit give the same scores.
+
+// inputPolicy makes the controller that flies the ship of a player during a batch game.
+type inputPolicy func(sim *theSimulation, player int, seed int64) controller
+
+// scriptedPolicy flies every ship with the same script.
+func scriptedPolicy(script func(frame int) controllerState) inputPolicy {
+	return func(sim *theSimulation, player int, seed int64) controller {
+		return &scriptedController{Script: script}
+	}
+}
+
+// randomPolicy mashes the controls, changing its mind every few frames.
+func randomPolicy(sim *theSimulation, player int, seed int64) controller {
+	var rng random
+	rng.Seed(seed*maxPlayers + int64(player))
+	var cs controllerState
+	return &scriptedController{Script: func(frame int) controllerState {
+		cs.Pressed = 0
+		if frame%10 == 0 {
+			cs.Turn = math.Floor(3*rng.Float64()) - 1
+			cs.Thrust = 0
+			if rng.Float64() < 0.3 {
+				cs.Thrust = 1
+			}
+			if rng.Float64() < 0.5 {
+				cs.Pressed = buttonFire
+			}
+		}
+		return cs
+	}}
+}
+
+var inputPolicies = map[string]inputPolicy{
+	"idle": scriptedPolicy(func(frame int) controllerState {
+		return controllerState{}
+	}),
+	"spin": scriptedPolicy(func(frame int) controllerState {
+		cs := controllerState{Turn: 1}
+		if frame%15 == 0 {
+			cs.Pressed = buttonFire
+		}
+		return cs
+	}),
+	"random": randomPolicy,
+}
+
+// gameResult is how far the players got in a game.
+type gameResult struct {
+	Seed   int64
+	Score  int
+	Level  int
+	Frames int
+	Over   bool // false when the game ran out of frames
+}
+
+// runGame plays a game without a window until it is over or has run for maxFrames.
+func runGame(seed int64, players int, versus bool, maxFrames int, policy inputPolicy) gameResult {
+	sim := newBareSimulation(seed)
+	if versus {
+		sim.NewMatch(seed, players, defaultVersusRules())
+	} else {
+		sim.NewGame(seed, players)
+	}
+	sim.Reset()
+
+	controllers := make([]controller, players)
+	for i := range controllers {
+		controllers[i] = policy(sim, i, seed)
+	}
+
+	states := make([]controllerState, players)
+	frames := 0
+	for ; frames < maxFrames && !netGameOver(sim); frames++ {
+		for i, c := range controllers {
+			states[i] = c.Poll()
+		}
+		netAdvance(sim, states)
+	}
+
+	return gameResult{
+		Seed:   seed,
+		Score:  sim.Score,
+		Level:  1 + sim.Level,
+		Frames: frames,
+		Over:   netGameOver(sim),
+	}
+}
+
+// benchReport sums up a batch of games.
+type benchReport struct {
+	Games          int     `json:"games"`
+	Policy         string  `json:"policy"`
+	ScoreMin       int     `json:"score_min"`
+	ScoreP10       int     `json:"score_p10"`
+	ScoreP25       int     `json:"score_p25"`
+	ScoreMedian    int     `json:"score_median"`
+	ScoreP75       int     `json:"score_p75"`
+	ScoreP90       int     `json:"score_p90"`
+	ScoreMax       int     `json:"score_max"`
+	ScoreMean      float64 `json:"score_mean"`
+	ScoreStdDev    float64 `json:"score_stddev"`
+	LevelMean      float64 `json:"level_mean"`
+	LevelMax       int     `json:"level_max"`
+	Stopped        int     `json:"stopped"` // games that ran out of frames
+	Frames         int     `json:"frames"`
+	Seconds        float64 `json:"seconds"`
+	FramesPerSec   float64 `json:"frames_per_second"`
+	AllocsPerFrame float64 `json:"allocs_per_frame"`
+	BytesPerFrame  float64 `json:"bytes_per_frame"`
+}
+
+func newBenchReport(policy string, results []gameResult, elapsed time.Duration, allocs, bytes uint64) benchReport {
+	r := benchReport{
+		Games:   len(results),
+		Policy:  policy,
+		Seconds: elapsed.Seconds(),
+	}
+	if len(results) == 0 {
+		return r
+	}
+
+	scores := make([]int, len(results))
+	levels := 0
+	for i, res := range results {
+		scores[i] = res.Score
+		levels += res.Level
+		r.Frames += res.Frames
+		if res.Level > r.LevelMax {
+			r.LevelMax = res.Level
+		}
+		if !res.Over {
+			r.Stopped++
+		}
+		r.ScoreMean += float64(res.Score)
+	}
+	r.ScoreMean /= float64(len(results))
+	r.LevelMean = float64(levels) / float64(len(results))
+
+	for _, s := range scores {
+		d := float64(s) - r.ScoreMean
+		r.ScoreStdDev += d * d
+	}
+	r.ScoreStdDev = math.Sqrt(r.ScoreStdDev / float64(len(results)))
+
+	sort.Ints(scores)
+	percentile := func(p int) int {
+		return scores[(len(scores)-1)*p/100]
+	}
+	r.ScoreMin, r.ScoreMax = scores[0], scores[len(scores)-1]
+	r.ScoreP10, r.ScoreP25, r.ScoreMedian = percentile(10), percentile(25), percentile(50)
+	r.ScoreP75, r.ScoreP90 = percentile(75), percentile(90)
+
+	if r.Frames > 0 {
+		r.FramesPerSec = float64(r.Frames) / math.Max(r.Seconds, 1e-9)
+		r.AllocsPerFrame = float64(allocs) / float64(r.Frames)
+		r.BytesPerFrame = float64(bytes) / float64(r.Frames)
+	}
+	return r
+}
+
+func (r benchReport) print(out io.Writer) {
+	fmt.Fprintf(out, "games   %d with the %s policy, %d stopped\n", r.Games, r.Policy, r.Stopped)
+	fmt.Fprintf(out, "score   min %d  p10 %d  p25 %d  median %d  p75 %d  p90 %d  max %d\n",
+		r.ScoreMin, r.ScoreP10, r.ScoreP25, r.ScoreMedian, r.ScoreP75, r.ScoreP90, r.ScoreMax)
+	fmt.Fprintf(out, "        mean %.1f  stddev %.1f\n", r.ScoreMean, r.ScoreStdDev)
+	fmt.Fprintf(out, "level   mean %.2f  max %d\n", r.LevelMean, r.LevelMax)
+	fmt.Fprintf(out, "speed   %d frames in %.2fs, %.0f frames per second\n", r.Frames, r.Seconds, r.FramesPerSec)
+	fmt.Fprintf(out, "memory  %.2f allocations and %.0f bytes per frame\n", r.AllocsPerFrame, r.BytesPerFrame)
+}
+
+// parseSeeds reads a list of seeds such as 1,2,10-20.
+func parseSeeds(list string) ([]int64, error) {
+	var seeds []int64
+	for _, field := range strings.Split(list, ",") {
+		field = strings.TrimSpace(field)
+		from, to := field, field
+		if i := strings.Index(field, "-"); i > 0 {
+			from, to = field[:i], field[i+1:]
+		}
+
+		a, err := strconv.ParseInt(from, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("bad seed %q", field)
+		}
+		b, err := strconv.ParseInt(to, 10, 64)
+		if err != nil || b < a {
+			return nil, fmt.Errorf("bad seed range %q", field)
+		}
+		for s := a; s <= b; s++ {
+			seeds = append(seeds, s)
+		}
+	}
+	return seeds, nil
+}
+
+// benchCommand plays a batch of games and reports the scores and the speed of the simulation.
+func benchCommand(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("bench", flag.ContinueOnError)
+	games := fs.Int("games", 100, "number of games to play")
+	seed := fs.Int64("seed", 1, "seed of the first game, the others count up from it")
+	seedList := fs.String("seeds", "", "seeds of the games to play, such as 1,2,10-20, instead of -games and -seed")
+	policy := fs.String("policy", "random", "input policy: idle, spin, random or bot")
+	difficulty := fs.String("difficulty", "normal", "difficulty of the bot policy")
+	players := fs.Int("players", 1, "number of players")
+	versus := fs.Bool("versus", false, "play versus matches instead of co-op games")
+	maxFrames := fs.Int("frames", 36000, "frame limit of a game")
+	verbose := fs.Bool("v", false, "print the result of every game")
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	if err := fs.Parse(args); err != nil {
+		return err
+	} else if *players < 1 || *players > maxPlayers {
+		return fmt.Errorf("the number of players must be between 1 and %d", maxPlayers)
+	} else if *versus && *players < 2 {
+		return fmt.Errorf("a versus match needs at least 2 players")
+	} else if *maxFrames < 1 {
+		return fmt.Errorf("the frame limit must be at least 1")
+	}
+
+	pol, ok := inputPolicies[*policy]
+	if *policy == "bot" {
+		settings, err := botDifficulty(*difficulty)
+		if err != nil {
+			return err
+		}
+		pol, ok = botPolicy(settings), true
+	}
+	if !ok {
+		return fmt.Errorf("unknown policy %q, choose one of idle, spin, random, bot", *policy)
+	}
+
+	var seeds []int64
+	if *seedList != "" {
+		var err error
+		if seeds, err = parseSeeds(*seedList); err != nil {
+			return err
+		}
+	} else {
+		for i := 0; i < *games; i++ {
+			seeds = append(seeds, *seed+int64(i))
+		}
+	}
+
+	results := make([]gameResult, 0, len(seeds))
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+	for _, s := range seeds {
+		results = append(results, runGame(s, *players, *versus, *maxFrames, pol))
+	}
+	elapsed := time.Since(start)
+	runtime.ReadMemStats(&after)
+
+	if *verbose {
+		for _, r := range results {
+			fmt.Fprintf(out, "seed %d: score %d, level %d, %d frames\n", r.Seed, r.Score, r.Level, r.Frames)
+		}
+	}
+
+	report := newBenchReport(*policy, results, elapsed, after.Mallocs-before.Mallocs, after.TotalAlloc-before.TotalAlloc)
+	if *asJSON {
+		return json.NewEncoder(out).Encode(report)
+	}
+	report.print(out)
+	return nil
+}
@@ -0,0 +1,214 @@
+//go:build !headless
+// +build !headless
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+
+	"github.com/askeladdk/pancake/input"
+)
+
+// bindingsFiles hold the key bindings of each player.
+var bindingsFiles = [maxPlayers]string{"keys.json", "keys2.json"}
+
+// maxKeysPerBinding is the number of keys that can trigger the same game action.
+const maxKeysPerBinding = 2
+
+type gameAction int
+
+const (
+	bindThrust gameAction = iota
+	bindTurnLeft
+	bindTurnRight
+	bindFire
+	bindHyperspace
+	bindRewind
+	bindPause
+	numBindings
+)
+
+var bindingNames = [numBindings]string{
+	bindThrust:     "thrust",
+	bindTurnLeft:   "turn_left",
+	bindTurnRight:  "turn_right",
+	bindFire:       "fire",
+	bindHyperspace: "hyperspace",
+	bindRewind:     "rewind",
+	bindPause:      "pause",
+}
+
+var bindingTitles = [numBindings]string{
+	bindThrust:     "Thrust",
+	bindTurnLeft:   "Turn left",
+	bindTurnRight:  "Turn right",
+	bindFire:       "Fire",
+	bindHyperspace: "Hyperspace",
+	bindRewind:     "Rewind",
+	bindPause:      "Pause",
+}
+
+// reservedKeys work the same for every player and cannot be bound to an action.
+// Escape is not among them, but it pauses the game even when it is not bound.
+var reservedKeys = map[input.Key]string{
+	input.KeyP:  "Spawn asteroid",
+	input.KeyF5: "Quick save",
+	input.KeyF9: "Quick load",
+}
+
+// keyBindings maps physical keys to game actions.
+// A key is bound to at most one action.
+type keyBindings [numBindings][]input.Key
+
+// defaultKeyBindings returns the bindings that a player starts with.
+// The second player shares the keyboard and takes over the arrow keys.
+func defaultKeyBindings(player int) keyBindings {
+	if player > 0 {
+		return keyBindings{
+			bindThrust:     {input.KeyUp},
+			bindTurnLeft:   {input.KeyLeft},
+			bindTurnRight:  {input.KeyRight},
+			bindFire:       {input.KeyRightControl, input.KeyEnter},
+			bindHyperspace: {input.KeyDown},
+			bindRewind:     {input.KeyRightShift},
+		}
+	}
+
+	return keyBindings{
+		bindThrust:     {input.KeyUp, input.KeyW},
+		bindTurnLeft:   {input.KeyLeft, input.KeyA},
+		bindTurnRight:  {input.KeyRight, input.KeyD},
+		bindFire:       {input.KeySpace},
+		bindHyperspace: {input.KeyDown, input.KeyS},
+		bindRewind:     {input.KeyR},
+		bindPause:      {input.KeyEscape},
+	}
+}
+
+// Lookup returns the action that a key is bound to.
+func (kb *keyBindings) Lookup(key input.Key) (gameAction, bool) {
+	for a, keys := range kb {
+		for _, k := range keys {
+			if k == key {
+				return gameAction(a), true
+			}
+		}
+	}
+	return 0, false
+}
+
+// Bind makes key the primary key of an action.
+// The key is taken away from any other action it was bound to,
+// and the oldest key of the action is dropped if there are too many.
+func (kb *keyBindings) Bind(a gameAction, key input.Key) {
+	kb.Unbind(key)
+	keys := append([]input.Key{key}, kb[a]...)
+	if len(keys) > maxKeysPerBinding {
+		keys = keys[:maxKeysPerBinding]
+	}
+	kb[a] = keys
+}
+
+// Unbind removes a key from whichever action it is bound to.
+func (kb *keyBindings) Unbind(key input.Key) {
+	for a, keys := range kb {
+		for i, k := range keys {
+			if k == key {
+				kb[a] = append(keys[:i:i], keys[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+func (kb *keyBindings) MarshalJSON() ([]byte, error) {
+	m := make(map[string][]string, numBindings)
+	for a, keys := range kb {
+		names := []string{}
+		for _, k := range keys {
+			if name, ok := keyName(k); ok {
+				names = append(names, name)
+			}
+		}
+		m[bindingNames[a]] = names
+	}
+	return json.Marshal(m)
+}
+
+// UnmarshalJSON decodes bindings leniently.
+// Unknown actions and keys are ignored, a key that is bound twice is kept by the first action,
+// and actions missing from the input keep those of their current keys that are still free.
+func (kb *keyBindings) UnmarshalJSON(data []byte) error {
+	var m map[string][]string
+	if err := json.Unmarshal(data, &m); err != nil {
+		return err
+	}
+
+	var result keyBindings
+	for a, name := range bindingNames {
+		for _, k := range m[name] {
+			if key, ok := keyByName(k); ok {
+				result.add(gameAction(a), key)
+			}
+		}
+	}
+
+	defaults := *kb
+	for a, name := range bindingNames {
+		if _, ok := m[name]; !ok {
+			for _, key := range defaults[a] {
+				result.add(gameAction(a), key)
+			}
+		}
+	}
+
+	*kb = result
+	return nil
+}
+
+// add binds a key to an action unless it is reserved, already taken or the action has no room for it.
+func (kb *keyBindings) add(a gameAction, key input.Key) {
+	if _, reserved := reservedKeys[key]; reserved {
+		return
+	} else if _, bound := kb.Lookup(key); !bound && len(kb[a]) < maxKeysPerBinding {
+		kb[a] = append(kb[a], key)
+	}
+}
+
+// loadKeyBindings reads the bindings of a player from the config file.
+// The defaults are returned if the file does not exist or cannot be read.
+func loadKeyBindings(player int) (keyBindings, error) {
+	kb := defaultKeyBindings(player)
+	filename, err := userFilePath(bindingsFiles[player])
+	if err != nil {
+		return kb, err
+	}
+
+	data, err := os.ReadFile(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return kb, nil
+	} else if err != nil {
+		return kb, err
+	}
+
+	loaded := kb
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		return kb, err
+	}
+	return loaded, nil
+}
+
+func saveKeyBindings(player int, kb *keyBindings) error {
+	filename, err := userFilePath(bindingsFiles[player])
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(kb, "", "\t")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filename, data)
+}
@@ -0,0 +1,168 @@
+//go:build !headless
+// +build !headless
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/askeladdk/pancake"
+	"github.com/askeladdk/pancake/graphics"
+	"github.com/askeladdk/pancake/input"
+	"github.com/askeladdk/pancake/mathx"
+	"github.com/askeladdk/pancake/text"
+)
+
+const (
+	bindingsDefaults = int(numBindings) + iota
+	bindingsBack
+)
+
+type bindingsScreen struct {
+	Player     int
+	Bindings   *keyBindings
+	Text       *text.Text
+	Drawer     *tintDrawer
+	Shader     *graphics.ShaderProgram
+	Background staticImage
+	Menu       menu
+	Capturing  bool
+	Pending    input.Key
+	Message    string
+	Done       bool
+}
+
+func newBindingsScreen(res *resources, player int) *bindingsScreen {
+	t := text.NewText(res.Font16)
+	t.Pos = mathx.Vec2{res.Midscreen()[0] - 128, 32}
+	return &bindingsScreen{
+		Player:     player,
+		Bindings:   res.Bindings[player],
+		Text:       t,
+		Drawer:     res.Drawer,
+		Shader:     res.Shader,
+		Background: res.Background,
+	}
+}
+
+func (s *bindingsScreen) Begin() {
+	s.Done = false
+	s.Capturing = false
+	s.Message = ""
+	s.Menu.Reset()
+	s.print()
+}
+
+func (s *bindingsScreen) End() {}
+
+func (s *bindingsScreen) Key(ev pancake.KeyEvent) error {
+	defer s.print()
+
+	if !ev.Flags.Pressed() {
+		return nil
+	} else if s.Capturing {
+		s.capture(ev.Key)
+		return nil
+	}
+
+	switch ev.Key {
+	case input.KeyEscape:
+		s.Done = true
+	case input.KeyBackspace:
+		fallthrough
+	case input.KeyDelete:
+		if s.Menu.Cursor < int(numBindings) {
+			s.Bindings[s.Menu.Cursor] = nil
+			s.save()
+		}
+	default:
+		s.Menu.Key(ev)
+	}
+	return nil
+}
+
+// capture binds the pressed key to the selected action.
+// A key that is already bound elsewhere must be pressed twice to confirm.
+func (s *bindingsScreen) capture(key input.Key) {
+	action := gameAction(s.Menu.Cursor)
+	name, ok := keyName(key)
+
+	if key == input.KeyEscape {
+		s.Capturing = false
+		s.Message = ""
+	} else if !ok {
+		s.Message = "That key cannot be bound."
+	} else if title, reserved := reservedKeys[key]; reserved {
+		s.Message = fmt.Sprintf("%s is reserved for %s.", name, title)
+	} else if owner, bound := s.Bindings.Lookup(key); bound && owner != action && key != s.Pending {
+		s.Pending = key
+		s.Message = fmt.Sprintf("%s is bound to %s. Press it again to rebind.", name, bindingTitles[owner])
+	} else {
+		s.Bindings.Bind(action, key)
+		s.Capturing = false
+		s.Message = ""
+		s.save()
+	}
+}
+
+func (s *bindingsScreen) Frame(ev pancake.FrameEvent) (screenOp, error) {
+	if choice, chosen := s.Menu.Choice(); chosen {
+		switch {
+		case choice < int(numBindings):
+			s.Capturing = true
+			s.Pending = 0
+			s.Message = "Press a key or Escape to cancel."
+		case choice == bindingsDefaults:
+			*s.Bindings = defaultKeyBindings(s.Player)
+			s.save()
+		case choice == bindingsBack:
+			s.Done = true
+		}
+		s.print()
+	}
+
+	if s.Done {
+		return pop(), nil
+	}
+	return screenOp{}, nil
+}
+
+func (s *bindingsScreen) Draw(ev pancake.DrawEvent) error {
+	s.Shader.Begin()
+	s.Drawer.Draw(s.Background)
+	s.Drawer.Draw(s.Text)
+	s.Shader.End()
+	return nil
+}
+
+func (s *bindingsScreen) save() {
+	if err := saveKeyBindings(s.Player, s.Bindings); err != nil {
+		fmt.Println(err)
+	}
+}
+
+func (s *bindingsScreen) print() {
+	items := make([]string, numBindings, numBindings+2)
+	for a, keys := range s.Bindings {
+		names := make([]string, 0, len(keys))
+		for _, k := range keys {
+			name, _ := keyName(k)
+			names = append(names, name)
+		}
+		if s.Capturing && a == s.Menu.Cursor {
+			names = []string{"..."}
+		}
+		items[a] = fmt.Sprintf("%s: %s", bindingTitles[a], strings.Join(names, ", "))
+	}
+	s.Menu.Items = append(items, "Reset to defaults", "Back")
+
+	s.Text.Clear()
+	fmt.Fprintf(s.Text, "Key bindings of player %d\n\n", 1+s.Player)
+	s.Menu.Print(s.Text)
+	if s.Message != "" {
+		fmt.Fprintf(s.Text, "\n%s", s.Message)
+	} else {
+		fmt.Fprintf(s.Text, "\nEnter to rebind, Backspace to clear.\nEscape always pauses the game.")
+	}
+}
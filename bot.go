@@ -0,0 +1,370 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/askeladdk/pancake/mathx"
+)
+
+// The autopilot flies a ship through the same controller state as a player.
+// It shoots the rocks that are about to hit it, or dodges them when it cannot,
+// and otherwise shoots at the one that it can hit soonest, aiming ahead of it.
+// It takes a moment to react to what happens and misses a little, depending on its difficulty.
+
+// botSettings make the autopilot easier or harder to play with or against.
+type botSettings struct {
+	ReactionTime float64 // seconds before the bot acts on what it sees
+	AimError     float64 // largest error of its aim in radians
+	FireInterval float64 // seconds between shots
+	Hyperspace   bool    // jump away from rocks that cannot be dodged
+}
+
+var botDifficulties = map[string]botSettings{
+	"easy":   {ReactionTime: 0.4, AimError: 0.15, FireInterval: 0.5},
+	"normal": {ReactionTime: 0.2, AimError: 0.06, FireInterval: 0.3},
+	"hard":   {ReactionTime: 0.05, AimError: 0.01, FireInterval: 0.15, Hyperspace: true},
+}
+
+func botDifficulty(name string) (botSettings, error) {
+	if s, ok := botDifficulties[name]; ok {
+		return s, nil
+	}
+
+	names := make([]string, 0, len(botDifficulties))
+	for name := range botDifficulties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return botSettings{}, fmt.Errorf("unknown difficulty %q, choose one of %s", name, strings.Join(names, ", "))
+}
+
+const (
+	botBulletSpeed    = 200  // initial speed of a bullet, see SpawnBullet
+	botBulletAcc      = 1.01 // acceleration of a bullet per frame
+	botThreatHorizon  = 0.8  // seconds ahead that the bot looks out for collisions
+	botThreatMargin   = 12   // extra distance that the bot keeps from rocks
+	botHyperspaceTime = 0.15 // a collision this close cannot be dodged
+	botTurnTolerance  = 0.02
+	botApproachSpeed  = 30 // the bot closes in on targets out of reach no faster than this
+)
+
+const (
+	botIDLE = iota
+	botATTACK
+	botDODGE
+)
+
+// botPlan is what the bot decided to do about what it saw.
+// It follows the plan until it has had the time to react to what happened since,
+// expecting everything to move on as it did.
+type botPlan struct {
+	Action int
+	Pos    mathx.Vec2 // where the target was
+	Vel    mathx.Vec2 // how fast the target was moving
+	Radius float64    // how large the target is
+	When   float64    // seconds until the hazard would hit
+	Offset mathx.Vec2 // where the hazard would pass the ship
+	Age    float64    // seconds since the plan was made
+}
+
+// botController is a controller that plays by itself.
+type botController struct {
+	Sim      *theSimulation
+	Player   int
+	Settings botSettings
+	Rand     random // the simulation has its own, which the bot must not disturb
+	plan     botPlan
+	planned  bool
+	cooldown float64
+	aimError float64
+}
+
+func newBotController(sim *theSimulation, player int, settings botSettings, seed int64) *botController {
+	b := &botController{
+		Sim:      sim,
+		Player:   player,
+		Settings: settings,
+	}
+	b.Rand.Seed(seed)
+	return b
+}
+
+func (b *botController) Poll() controllerState {
+	b.plan.Age += netTickTime
+	if b.cooldown > 0 {
+		b.cooldown -= netTickTime
+	}
+
+	i := b.Sim.Ship(b.Player)
+	if i < 0 {
+		b.planned = false
+		return controllerState{}
+	}
+
+	ship := b.Sim.At(i)
+	if !b.planned || b.plan.Age >= b.Settings.ReactionTime {
+		b.plan = b.makePlan(ship)
+		b.planned = true
+	}
+
+	switch b.plan.Action {
+	case botATTACK:
+		return b.attack(ship)
+	case botDODGE:
+		return b.dodge(ship)
+	}
+	return controllerState{}
+}
+
+func (b *botController) Reset() {
+	b.planned = false
+	b.cooldown = 0
+}
+
+// makePlan shoots a rock on its way, if there is time to turn towards it, and dodges it otherwise.
+// When nothing is on its way it goes after the target that it can hit soonest.
+func (b *botController) makePlan(ship *entity) botPlan {
+	threat, when, offset := b.threat(ship)
+	if threat != nil && !(isTarget(b.Sim, b.Player, threat) && b.canShoot(ship, threat, when)) {
+		return botPlan{Action: botDODGE, When: when, Offset: offset}
+	} else if threat == nil {
+		threat = b.choose(ship)
+	}
+
+	if threat == nil {
+		return botPlan{}
+	}
+	return botPlan{Action: botATTACK, Pos: threat.Pos, Vel: threat.Vel, Radius: threat.Radius}
+}
+
+// isHazard reports whether an entity can destroy the ship of a player.
+func isHazard(sim *theSimulation, player int, e *entity) bool {
+	if e.Mask&(flagASTEROID|flagDEBRIS) != 0 {
+		return true
+	}
+	return e.Mask&flagBULLET != 0 && e.Player != player && sim.Mode == modeVERSUS
+}
+
+// isTarget reports whether an entity is worth shooting at for a player.
+func isTarget(sim *theSimulation, player int, e *entity) bool {
+	if e.Mask&(flagASTEROID|flagDEBRIS) != 0 {
+		return true
+	}
+	return e.Mask&flagSPACESHIP != 0 && e.Player != player && sim.Mode == modeVERSUS
+}
+
+// threat returns the hazard that is going to hit the ship first, if any,
+// when that happens and where it passes closest to the ship.
+func (b *botController) threat(ship *entity) (*entity, float64, mathx.Vec2) {
+	var threat *entity
+	var offset mathx.Vec2
+	soonest := botThreatHorizon
+	for i := range b.Sim.Entities {
+		e := b.Sim.At(i)
+		if !isHazard(b.Sim, b.Player, e) {
+			continue
+		}
+
+		r := wrapDelta(ship.Pos, e.Pos, b.Sim.Bounds)
+		w := e.Vel.Sub(ship.Vel)
+		t := 0.0
+		if ww := dot(w, w); ww > 0 {
+			t = math.Max(0, -dot(r, w)/ww)
+		}
+
+		closest := r.Add(w.Mul(t))
+		if t < soonest && closest.Len() < e.Radius+ship.Radius+botThreatMargin {
+			threat, soonest, offset = e, t, closest
+			if offset.Len() < 1 {
+				offset = mathx.Vec2{-w[1], w[0]}
+			}
+		}
+	}
+	return threat, soonest, offset
+}
+
+// canShoot reports whether the ship can turn towards a target and hit it before a time.
+func (b *botController) canShoot(ship, e *entity, before float64) bool {
+	aim, t := intercept(wrapDelta(ship.Pos, e.Pos, b.Sim.Bounds), e.Vel)
+	diff := angleDiff(math.Atan2(aim[1], aim[0]), ship.Rot)
+	return turnTime(ship, diff)+t < before
+}
+
+// choose returns the target that the ship can hit soonest, counting the time to turn towards it.
+func (b *botController) choose(ship *entity) *entity {
+	var target *entity
+	soonest := math.Inf(1)
+	for i := range b.Sim.Entities {
+		e := b.Sim.At(i)
+		if !isTarget(b.Sim, b.Player, e) {
+			continue
+		}
+
+		aim, t := intercept(wrapDelta(ship.Pos, e.Pos, b.Sim.Bounds), e.Vel)
+		t += turnTime(ship, angleDiff(math.Atan2(aim[1], aim[0]), ship.Rot))
+		if t < soonest {
+			target, soonest = e, t
+		}
+	}
+	return target
+}
+
+// dodge moves away from where a hazard is going to pass, or jumps away if it is too late for that.
+func (b *botController) dodge(ship *entity) controllerState {
+	var cs controllerState
+	if b.plan.When-b.plan.Age < botHyperspaceTime && b.Settings.Hyperspace && b.cooldown <= 0 {
+		cs.Pressed = buttonHyperspace
+		b.cooldown = b.Settings.FireInterval
+		return cs
+	}
+
+	offset := b.plan.Offset
+	heading := math.Atan2(-offset[1], -offset[0])
+	var diff float64
+	cs.Turn, diff = steer(ship, heading)
+	if math.Abs(diff) < mathx.Tau/8 {
+		cs.Thrust = 1
+	}
+	return cs
+}
+
+// attack turns towards the target and fires when it is lined up and within reach.
+func (b *botController) attack(ship *entity) controllerState {
+	var cs controllerState
+	pos := b.plan.Pos.Add(b.plan.Vel.Mul(b.plan.Age))
+	aim, t := intercept(wrapDelta(ship.Pos, pos, b.Sim.Bounds), b.plan.Vel)
+	heading := math.Atan2(aim[1], aim[0]) + b.aimError
+	var diff float64
+	cs.Turn, diff = steer(ship, heading)
+
+	dist := aim.Len()
+	lined := math.Abs(diff) < math.Max(botTurnTolerance, math.Atan2(b.plan.Radius, dist)*0.8)
+	if lined && t <= bulletLifetime && b.cooldown <= 0 {
+		cs.Pressed |= buttonFire
+		b.cooldown = b.Settings.FireInterval
+		b.aimError = (2*b.Rand.Float64() - 1) * b.Settings.AimError
+	} else if lined && t > bulletLifetime && dot(ship.Vel.Sub(b.plan.Vel), aim)/dist < botApproachSpeed {
+		cs.Thrust = 1
+	}
+	return cs
+}
+
+// intercept returns where to aim to hit something at a relative position that moves at a velocity,
+// and how long the bullet takes to get there, which is infinite when it cannot.
+func intercept(r, v mathx.Vec2) (mathx.Vec2, float64) {
+	p, t := r, 0.0
+	for i := 0; i < 4; i++ {
+		t = bulletTime(p.Len())
+		p = r.Add(v.Mul(t))
+	}
+	return p, t
+}
+
+// bulletTime returns the time that a bullet takes to cover a distance. Bullets speed up as they go.
+func bulletTime(dist float64) float64 {
+	perFrame := botBulletSpeed * netTickTime
+	frames := math.Log(1+dist*(botBulletAcc-1)/perFrame) / math.Log(botBulletAcc)
+	return math.Ceil(frames) * netTickTime
+}
+
+// steer turns a ship towards a heading and returns how far it is off.
+// Turning sets the rotation speed instead of adding to it, so the ship can turn
+// back and forth to stay on course instead of drifting on after it stops turning.
+func steer(ship *entity, heading float64) (float64, float64) {
+	diff := angleDiff(heading, ship.Rot)
+	if diff > botTurnTolerance {
+		return 1, diff
+	} else if diff < -botTurnTolerance {
+		return -1, diff
+	}
+	return 0, diff
+}
+
+// turnTime returns how long a ship takes to turn by an angle.
+func turnTime(ship *entity, angle float64) float64 {
+	perFrame := ship.Turn * ship.Turn * netTickTime * ship.RotA
+	return math.Abs(angle) / perFrame * netTickTime
+}
+
+// angleDiff returns the smallest rotation from b to a, between -π and π.
+func angleDiff(a, b float64) float64 {
+	d := math.Mod(a-b, mathx.Tau)
+	if d > math.Pi {
+		d -= mathx.Tau
+	} else if d < -math.Pi {
+		d += mathx.Tau
+	}
+	return d
+}
+
+// wrapDelta returns the shortest way from a to b on a playing field whose edges wrap around.
+func wrapDelta(a, b mathx.Vec2, bounds mathx.Rectangle) mathx.Vec2 {
+	d := b.Sub(a)
+	size := bounds.Max.Sub(bounds.Min)
+	for i := range d {
+		if d[i] > size[i]/2 {
+			d[i] -= size[i]
+		} else if d[i] < -size[i]/2 {
+			d[i] += size[i]
+		}
+	}
+	return d
+}
+
+func dot(a, b mathx.Vec2) float64 {
+	return a[0]*b[0] + a[1]*b[1]
+}
+
+// botPolicy lets the autopilot fly every ship.
+func botPolicy(settings botSettings) inputPolicy {
+	return func(sim *theSimulation, player int, seed int64) controller {
+		return newBotController(sim, player, settings, seed+int64(player))
+	}
+}
+
+// botCommand lets the autopilot play games without a window and reports how it did.
+func botCommand(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("bot", flag.ContinueOnError)
+	games := fs.Int("games", 10, "number of games to play")
+	seed := fs.Int64("seed", 1, "seed of the first game, the others count up from it")
+	difficulty := fs.String("difficulty", "normal", "easy, normal or hard")
+	players := fs.Int("players", 1, "number of ships that the autopilot flies")
+	versus := fs.Bool("versus", false, "let the ships fight a versus match")
+	minutes := fs.Float64("minutes", 10, "longest game time before a game is stopped")
+	if err := fs.Parse(args); err != nil {
+		return err
+	} else if *players < 1 || *players > maxPlayers {
+		return fmt.Errorf("the number of players must be between 1 and %d", maxPlayers)
+	} else if *versus && *players < 2 {
+		return fmt.Errorf("a versus match needs at least 2 players")
+	}
+
+	settings, err := botDifficulty(*difficulty)
+	if err != nil {
+		return err
+	}
+
+	maxFrames := int(*minutes * 60 * netTickRate)
+	var totalScore, totalLevel int
+	for i := 0; i < *games; i++ {
+		r := runGame(*seed+int64(i), *players, *versus, maxFrames, botPolicy(settings))
+		fmt.Fprintf(out, "seed %d: score %d, level %d, %.1f seconds", r.Seed, r.Score, r.Level, float64(r.Frames)*netTickTime)
+		if !r.Over {
+			fmt.Fprint(out, " (stopped)")
+		}
+		fmt.Fprintln(out)
+		totalScore += r.Score
+		totalLevel += r.Level
+	}
+
+	if *games > 0 {
+		fmt.Fprintf(out, "average score %.0f, average level %.1f\n",
+			float64(totalScore)/float64(*games), float64(totalLevel)/float64(*games))
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+//go:build !headless
+// +build !headless
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/askeladdk/pancake"
+)
+
+// clientScreen shows a game that runs on a server.
+// The simulation is not advanced here but replaced by every snapshot,
+// whose previous positions are used to draw the entities in between ticks.
+type clientScreen struct {
+	*gameScreen
+	Link *serverLink
+}
+
+func newClientScreen(res *resources, sess *session, link *serverLink) *clientScreen {
+	return &clientScreen{
+		gameScreen: newGameScreen(res, sess),
+		Link:       link,
+	}
+}
+
+func (s *clientScreen) Begin() {
+	s.ResetControllers()
+	s.Floaters.Clear()
+}
+
+func (s *clientScreen) Key(ev pancake.KeyEvent) error {
+	if kr, ok := s.Controllers[0].(keyReceiver); ok {
+		kr.Key(ev)
+	}
+	return nil
+}
+
+func (s *clientScreen) leave() (screenOp, error) {
+	s.Link.Close()
+	return replaceAll(newTitleScreen(s.Res, s.session)), nil
+}
+
+func (s *clientScreen) Frame(ev pancake.FrameEvent) (screenOp, error) {
+	events := s.Link.Receive(s.Sim)
+	if err := s.Link.Err; err != nil {
+		fmt.Println(err)
+		return s.leave()
+	}
+
+	cs := s.Controllers[0].Poll()
+	if cs.Pressed&buttonPause != 0 {
+		return s.leave()
+	}
+	s.Link.Send(cs)
+
+	for _, e := range events {
+		if snd, ok := eventSounds[e.Code]; ok {
+			s.Sim.PlaySound(snd)
+		}
+	}
+	s.Floaters.Frame(ev.DeltaTime)
+	s.Floaters.AddEvents(events)
+
+	s.printStatus()
+	switch {
+	case s.Link.Tick < 0:
+		fmt.Fprintf(s.Text, "\nConnecting to %s...", s.Link.Conn.RemoteAddr())
+	case s.Link.Missing > 0:
+		fmt.Fprintf(s.Text, "\nWaiting for %d more players...", s.Link.Missing)
+	case netGameOver(s.Sim):
+		fmt.Fprintf(s.Text, "\nGame over! The next game starts soon.")
+	}
+	return screenOp{}, nil
+}
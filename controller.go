@@ -0,0 +1,101 @@
+package main
+
+import "sync"
+
+type controllerButtons uint32
+
+const (
+	buttonFire controllerButtons = 1 << iota
+	buttonHyperspace
+	buttonPause
+	buttonQuickSave
+	buttonQuickLoad
+	buttonSpawnAsteroid
+	buttonRewind
+)
+
+// controllerState is a snapshot of a virtual controller.
+type controllerState struct {
+	Turn    float64           // -1 turns left, +1 turns right
+	Thrust  float64           // 0 is idle, 1 is full thrust
+	Held    controllerButtons // buttons that are held down
+	Pressed controllerButtons // buttons that went down since the previous poll
+}
+
+// Apply issues the simulation actions of a controller state to the ship of a player, if it has one.
+func (cs controllerState) Apply(sim *theSimulation, player int) {
+	entityID := sim.Ship(player)
+	if entityID < 0 {
+		return
+	}
+
+	if cs.Turn != 0 {
+		sim.Action(entityID, actionTurn, cs.Turn)
+	}
+
+	if cs.Thrust != 0 {
+		sim.Action(entityID, actionForward, cs.Thrust)
+	}
+
+	if cs.Pressed&buttonFire != 0 {
+		sim.Action(entityID, actionFire, 0)
+	}
+
+	if cs.Pressed&buttonHyperspace != 0 {
+		sim.Action(entityID, actionHyperspace, 0)
+	}
+}
+
+// controller is a virtual game pad owned by a player.
+// It is fed by a device such as the keyboard, a script or the network.
+type controller interface {
+	// Poll returns the state for the next simulation frame.
+	Poll() controllerState
+
+	// Reset releases all buttons, for example when a menu takes over the input.
+	Reset()
+}
+
+// scriptedController replays controller states produced by a function of the frame number.
+// It can be used by bots and to drive the game without a keyboard.
+type scriptedController struct {
+	Script func(frame int) controllerState
+	Frame  int
+}
+
+func (sc *scriptedController) Poll() controllerState {
+	cs := sc.Script(sc.Frame)
+	sc.Frame++
+	return cs
+}
+
+func (sc *scriptedController) Reset() {}
+
+// remoteController is fed from somewhere else, for example by the packets of a network client.
+// Button presses are kept until they are polled so that none are lost.
+type remoteController struct {
+	mu    sync.Mutex
+	state controllerState
+}
+
+func (rc *remoteController) Feed(cs controllerState) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	pressed := rc.state.Pressed | cs.Pressed
+	rc.state = cs
+	rc.state.Pressed = pressed
+}
+
+func (rc *remoteController) Poll() controllerState {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	cs := rc.state
+	rc.state.Pressed = 0
+	return cs
+}
+
+func (rc *remoteController) Reset() {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.state = controllerState{}
+}
@@ -0,0 +1,70 @@
+package main
+
+import "testing"
+
+// playFrames advances a session like the game screen does: it polls the controllers
+// of the players in the game, applies their state and simulates a frame.
+func playFrames(sess *session, frames int) {
+	for i := 0; i < frames; i++ {
+		for p, c := range sess.Active() {
+			c.Poll().Apply(sess.Sim, p)
+		}
+		sess.Sim.Frame(runFrameTime)
+	}
+}
+
+func TestScriptedControllerDrivesTheGame(t *testing.T) {
+	pilot := &scriptedController{Script: func(frame int) controllerState {
+		cs := controllerState{Turn: 1, Thrust: 1}
+		if frame == 0 {
+			cs.Pressed = buttonFire
+		}
+		return cs
+	}}
+	idle := &scriptedController{Script: func(int) controllerState { return controllerState{} }}
+
+	sess := newSession(newBareSimulation(1), []controller{pilot, idle}, nil)
+	sess.NewGame(1, 2)
+	sess.Sim.Reset()
+	start := *sess.Sim.At(sess.Sim.Ship(0))
+	idleStart := *sess.Sim.At(sess.Sim.Ship(1))
+
+	playFrames(sess, 1)
+	fired := false
+	for _, ev := range sess.Sim.Events {
+		fired = fired || ev.Code == eventFIRE
+	}
+	if !fired {
+		t.Fatalf("the first frame did not fire a shot: %+v", sess.Sim.Events)
+	}
+
+	playFrames(sess, 29)
+	if pilot.Frame != 30 || idle.Frame != 30 {
+		t.Fatalf("the controllers were polled %d and %d times", pilot.Frame, idle.Frame)
+	}
+	ship := sess.Sim.At(sess.Sim.Ship(0))
+	if ship.Rot == start.Rot || ship.Pos == start.Pos {
+		t.Fatal("the ship of player 1 did not turn and move")
+	}
+	other := sess.Sim.At(sess.Sim.Ship(1))
+	if other.Rot != idleStart.Rot || other.Pos != idleStart.Pos {
+		t.Fatal("the ship of player 2 moved without input")
+	}
+}
+
+func TestRemoteControllerKeepsPresses(t *testing.T) {
+	var rc remoteController
+	rc.Feed(controllerState{Turn: 1, Pressed: buttonFire})
+	rc.Feed(controllerState{Thrust: 1, Pressed: buttonHyperspace})
+
+	if cs := rc.Poll(); cs.Turn != 0 || cs.Thrust != 1 || cs.Pressed != buttonFire|buttonHyperspace {
+		t.Fatalf("polled %+v", cs)
+	}
+	if cs := rc.Poll(); cs.Thrust != 1 || cs.Pressed != 0 {
+		t.Fatalf("polled %+v after the presses were polled", cs)
+	}
+	rc.Reset()
+	if cs := rc.Poll(); cs != (controllerState{}) {
+		t.Fatalf("polled %+v after a reset", cs)
+	}
+}
@@ -0,0 +1,368 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/askeladdk/pancake/mathx"
+)
+
+// The simulation must do exactly the same with the same seed and input, or network games drift
+// apart and run logs cannot be verified. A stable hash of the state after every tick shows when
+// two simulations part ways. The desync command plays the same game on two simulations side by
+// side and shows what differs at the first tick where their hashes do not match. It can also
+// write the hashes to a file to compare the game on two machines or builds.
+
+const (
+	hashOffset = 14695981039346656037
+	hashPrime  = 1099511628211
+)
+
+// stateHash is FNV-1a over 64-bit words instead of bytes, which is fast enough to run every tick.
+type stateHash uint64
+
+func (h *stateHash) u64(v uint64)  { *h = (*h ^ stateHash(v)) * hashPrime }
+func (h *stateHash) i64(v int64)   { h.u64(uint64(v)) }
+func (h *stateHash) f64(v float64) { h.u64(math.Float64bits(v)) }
+func (h *stateHash) count(n int)   { h.u64(uint64(n)) }
+
+func (h *stateHash) vec2(v mathx.Vec2) {
+	h.f64(v[0])
+	h.f64(v[1])
+}
+
+func (h *stateHash) bool(v bool) {
+	if v {
+		h.u64(1)
+	} else {
+		h.u64(0)
+	}
+}
+
+// StateHash returns a hash of everything that decides what happens next: the entities,
+// the scores, the players, the rules, the physics and the random number generator. It does not allocate,
+// so that netplay can check that two games are still in step after every tick.
+func (s *theSimulation) StateHash() uint64 {
+	h := stateHash(hashOffset)
+	h.i64(int64(s.State))
+	h.i64(int64(s.Mode))
+	h.i64(int64(s.Level))
+	h.i64(int64(s.Score))
+	h.i64(int64(s.Remaining))
+	h.i64(int64(s.Multiplier))
+	h.f64(s.ComboTime)
+	h.bool(s.Damaged)
+	h.i64(int64(s.RoundWinner))
+	h.u64(s.Rand.State)
+	h.bool(s.Fixed)
+	h.i64(int64(s.Rules.RoundsToWin))
+	h.i64(int64(s.Rules.Lives))
+	h.i64(int64(s.Rules.Asteroids))
+	h.bool(s.Rules.FriendlyFire)
+
+	h.count(len(s.Players))
+	for _, p := range s.Players {
+		h.i64(int64(p.Score))
+		h.i64(int64(p.Lives))
+		h.f64(p.Respawn)
+		h.i64(int64(p.Wins))
+	}
+
+	h.count(len(s.Actions))
+	for _, a := range s.Actions {
+		h.i64(int64(a.EntityID))
+		h.i64(int64(a.Code))
+		h.f64(a.Value)
+	}
+
+	h.count(len(s.Entities))
+	for i := range s.Entities {
+		e := &s.Entities[i]
+		h.i64(int64(e.ImageID))
+		h.vec2(e.Pos)
+		h.vec2(e.Vel)
+		h.f64(e.Rot)
+		h.f64(e.RotV)
+		h.f64(e.Acc)
+		h.f64(e.RotA)
+		h.f64(e.MaxV)
+		h.f64(e.MinRotV)
+		h.f64(e.Turn)
+		h.f64(e.Thrust)
+		h.u64(uint64(e.Mask))
+		h.f64(e.Radius)
+		h.f64(e.Lifetime)
+		h.vec2(e.Pos0)
+		h.f64(e.Rot0)
+		h.i64(int64(e.Kills))
+		h.i64(int64(e.Player))
+	}
+	return uint64(h)
+}
+
+// simSummary is the part of the simulation besides the players, actions and entities.
+type simSummary struct {
+	State       gameState
+	Mode        gameMode
+	Level       int
+	Score       int
+	Remaining   int
+	Multiplier  int
+	ComboTime   float64
+	Damaged     bool
+	RoundWinner int
+	Rand        uint64
+	Fixed       bool
+	Rules       versusRules
+}
+
+func summarize(s *theSimulation) simSummary {
+	return simSummary{
+		State:       s.State,
+		Mode:        s.Mode,
+		Level:       s.Level,
+		Score:       s.Score,
+		Remaining:   s.Remaining,
+		Multiplier:  s.Multiplier,
+		ComboTime:   s.ComboTime,
+		Damaged:     s.Damaged,
+		RoundWinner: s.RoundWinner,
+		Rand:        s.Rand.State,
+		Fixed:       s.Fixed,
+		Rules:       s.Rules,
+	}
+}
+
+// diffFields lists the fields of two structs of the same type that differ.
+func diffFields(prefix string, a, b interface{}) []string {
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	var diffs []string
+	for i := 0; i < va.NumField(); i++ {
+		fa, fb := va.Field(i).Interface(), vb.Field(i).Interface()
+		if !reflect.DeepEqual(fa, fb) {
+			diffs = append(diffs, fmt.Sprintf("%s%s: %v != %v", prefix, va.Type().Field(i).Name, fa, fb))
+		}
+	}
+	return diffs
+}
+
+// diffSimulations lists what differs between two simulations, down to the fields of the first
+// player, action and entity that differ.
+func diffSimulations(a, b *theSimulation) []string {
+	diffs := diffFields("", summarize(a), summarize(b))
+
+	if len(a.Players) != len(b.Players) {
+		diffs = append(diffs, fmt.Sprintf("players: %d != %d", len(a.Players), len(b.Players)))
+	}
+	for i := 0; i < minInt(len(a.Players), len(b.Players)); i++ {
+		if d := diffFields(fmt.Sprintf("player %d: ", i+1), a.Players[i], b.Players[i]); len(d) > 0 {
+			diffs = append(diffs, d...)
+			break
+		}
+	}
+
+	if len(a.Actions) != len(b.Actions) {
+		diffs = append(diffs, fmt.Sprintf("actions: %d != %d", len(a.Actions), len(b.Actions)))
+	}
+	for i := 0; i < minInt(len(a.Actions), len(b.Actions)); i++ {
+		if d := diffFields(fmt.Sprintf("action %d: ", i), a.Actions[i], b.Actions[i]); len(d) > 0 {
+			diffs = append(diffs, d...)
+			break
+		}
+	}
+
+	if len(a.Entities) != len(b.Entities) {
+		diffs = append(diffs, fmt.Sprintf("entities: %d != %d", len(a.Entities), len(b.Entities)))
+	}
+	for i := 0; i < minInt(len(a.Entities), len(b.Entities)); i++ {
+		if d := diffFields(fmt.Sprintf("entity %d (mask %#x): ", i, a.Entities[i].Mask), a.Entities[i], b.Entities[i]); len(d) > 0 {
+			diffs = append(diffs, d...)
+			break
+		}
+	}
+	return diffs
+}
+
+// desyncGame plays the same game on two simulations, one tick at a time.
+type desyncGame struct {
+	A, B    *theSimulation
+	Advance func() error // simulates the next tick on both
+	Done    func() bool
+}
+
+// newPolicyDesyncGame plays a game with the input of a policy, which only sees the first
+// simulation. The second gets the same input.
+func newPolicyDesyncGame(seed int64, players int, versus, fixedPoint bool, policy inputPolicy) *desyncGame {
+	g := &desyncGame{A: newBareSimulation(seed), B: newBareSimulation(seed)}
+	for _, sim := range []*theSimulation{g.A, g.B} {
+		sim.Fixed = fixedPoint
+		if versus {
+			sim.NewMatch(seed, players, defaultVersusRules())
+		} else {
+			sim.NewGame(seed, players)
+		}
+		sim.Reset()
+	}
+
+	controllers := make([]controller, players)
+	for i := range controllers {
+		controllers[i] = policy(g.A, i, seed)
+	}
+	states := make([]controllerState, players)
+	g.Advance = func() error {
+		for i, c := range controllers {
+			states[i] = c.Poll()
+		}
+		netAdvance(g.A, states)
+		netAdvance(g.B, states)
+		return nil
+	}
+	g.Done = func() bool {
+		return netGameOver(g.A) || netGameOver(g.B)
+	}
+	return g
+}
+
+// newRunLogDesyncGame plays a run log on both simulations.
+func newRunLogDesyncGame(l *runLog) *desyncGame {
+	g := &desyncGame{A: newBareSimulation(l.Seed), B: newBareSimulation(l.Seed)}
+	a, b := newRunReplay(l, g.A), newRunReplay(l, g.B)
+	g.Advance = func() error {
+		if err := a.Step(); err != nil {
+			return err
+		}
+		return b.Step()
+	}
+	g.Done = a.Done
+	return g
+}
+
+// Run plays the game until it is over or has run for maxFrames and returns the hashes of the first
+// simulation. It stops at the first tick where the simulations differ and lists the differences.
+func (g *desyncGame) Run(maxFrames int) (hashes []uint64, diffs []string, err error) {
+	g.A.Hashing, g.B.Hashing = true, true
+	for tick := 0; tick < maxFrames && !g.Done(); tick++ {
+		if err := g.Advance(); err != nil {
+			return hashes, nil, err
+		}
+		hashes = append(hashes, g.A.TickHash)
+		if g.A.TickHash != g.B.TickHash {
+			return hashes, diffSimulations(g.A, g.B), nil
+		}
+	}
+	return hashes, nil, nil
+}
+
+func writeHashTrace(filename string, hashes []uint64) error {
+	var sb strings.Builder
+	for _, h := range hashes {
+		fmt.Fprintf(&sb, "%016x\n", h)
+	}
+	return os.WriteFile(filename, []byte(sb.String()), 0644)
+}
+
+func readHashTrace(filename string) ([]uint64, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var hashes []uint64
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		h, err := strconv.ParseUint(strings.TrimSpace(sc.Text()), 16, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: bad hash", filename, len(hashes)+1)
+		}
+		hashes = append(hashes, h)
+	}
+	return hashes, sc.Err()
+}
+
+// desyncCommand plays a game twice side by side and reports the first tick at which they differ.
+func desyncCommand(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("desync", flag.ContinueOnError)
+	seed := fs.Int64("seed", 1, "seed of the game")
+	policy := fs.String("policy", "random", "input policy: idle, spin, random or bot")
+	difficulty := fs.String("difficulty", "normal", "difficulty of the bot policy")
+	players := fs.Int("players", 1, "number of players")
+	versus := fs.Bool("versus", false, "play a versus match instead of a co-op game")
+	fixedPoint := fs.Bool("fixed", false, "simulate with fixed-point math")
+	maxFrames := fs.Int("frames", 36000, "frame limit of the game")
+	logFile := fs.String("log", "", "play the input of a run log instead of a policy")
+	trace := fs.String("trace", "", "write the hash of every tick to a file")
+	against := fs.String("against", "", "compare the hashes with a file written by -trace on another machine or build")
+	if err := fs.Parse(args); err != nil {
+		return err
+	} else if *players < 1 || *players > maxPlayers {
+		return fmt.Errorf("the number of players must be between 1 and %d", maxPlayers)
+	} else if *versus && *players < 2 {
+		return fmt.Errorf("a versus match needs at least 2 players")
+	}
+
+	var g *desyncGame
+	if *logFile != "" {
+		l, err := loadRunLog(*logFile)
+		if err != nil {
+			return err
+		}
+		g = newRunLogDesyncGame(l)
+	} else {
+		pol, ok := inputPolicies[*policy]
+		if *policy == "bot" {
+			settings, err := botDifficulty(*difficulty)
+			if err != nil {
+				return err
+			}
+			pol, ok = botPolicy(settings), true
+		}
+		if !ok {
+			return fmt.Errorf("unknown policy %q, choose one of idle, spin, random, bot", *policy)
+		}
+		g = newPolicyDesyncGame(*seed, *players, *versus, *fixedPoint, pol)
+	}
+
+	hashes, diffs, err := g.Run(*maxFrames)
+	if err != nil {
+		return fmt.Errorf("tick %d: %v", len(hashes)+1, err)
+	}
+	if *trace != "" {
+		if err := writeHashTrace(*trace, hashes); err != nil {
+			return err
+		}
+	}
+	if g.A.TickHash != g.B.TickHash {
+		tick := len(hashes)
+		fmt.Fprintf(out, "tick %d: the simulations differ, %016x != %016x\n", tick, g.A.TickHash, g.B.TickHash)
+		for _, d := range diffs {
+			fmt.Fprintf(out, "  %s\n", d)
+		}
+		return fmt.Errorf("the simulation is not deterministic")
+	}
+	fmt.Fprintf(out, "%d ticks in step, final hash %016x\n", len(hashes), g.A.TickHash)
+
+	if *against != "" {
+		other, err := readHashTrace(*against)
+		if err != nil {
+			return err
+		}
+		for i := 0; i < minInt(len(hashes), len(other)); i++ {
+			if hashes[i] != other[i] {
+				return fmt.Errorf("tick %d differs from %s: %016x != %016x", i+1, *against, hashes[i], other[i])
+			}
+		}
+		if len(hashes) != len(other) {
+			return fmt.Errorf("%d ticks were played here but %d in %s", len(hashes), len(other), *against)
+		}
+		fmt.Fprintf(out, "the same as %s\n", *against)
+	}
+	return nil
+}
@@ -0,0 +1,21 @@
+package main
+
+import "testing"
+
+func TestStateHashCoversRulesAndPhysics(t *testing.T) {
+	sim := newBareSimulation(1)
+	sim.NewMatch(1, 2, defaultVersusRules())
+	sim.Reset()
+	hash := sim.StateHash()
+
+	sim.Fixed = true
+	if sim.StateHash() == hash {
+		t.Fatal("the hash does not change with the physics")
+	}
+	sim.Fixed = false
+
+	sim.Rules.FriendlyFire = !sim.Rules.FriendlyFire
+	if sim.StateHash() == hash {
+		t.Fatal("the hash does not change with the rules")
+	}
+}
@@ -0,0 +1,563 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+
+	"github.com/askeladdk/pancake/mathx"
+)
+
+// The environment runs the simulation one step at a time for agents that learn to play,
+// in the style of a Gym environment: Reset starts an episode and returns what the players see,
+// and Step applies their actions and returns what they see next, their rewards and whether the episode is over.
+// Every player sees the game from its own ship, encoded as a fixed number of values.
+
+const (
+	envSpeedScale  = 200 // speeds are divided by this to keep the observations around [-1, 1]
+	envRadiusScale = 32
+	shipFeatures   = 5 // alive, cosine and sine of the heading, velocity
+)
+
+// gymAction is what a player does during a step.
+type gymAction struct {
+	Turn       float64 `json:"turn"`   // -1 turns left, +1 turns right
+	Thrust     float64 `json:"thrust"` // 0 is idle, 1 is full thrust
+	Fire       bool    `json:"fire"`
+	Hyperspace bool    `json:"hyperspace"`
+}
+
+func (a gymAction) controllerState() controllerState {
+	cs := controllerState{
+		Turn:   mathx.Clamp(a.Turn, -1, 1),
+		Thrust: mathx.Clamp(a.Thrust, 0, 1),
+	}
+	if a.Fire {
+		cs.Pressed |= buttonFire
+	}
+	if a.Hyperspace {
+		cs.Pressed |= buttonHyperspace
+	}
+	return cs
+}
+
+// observationEncoding turns the game into the values that a player sees.
+type observationEncoding interface {
+	// Size returns the number of values in an observation.
+	Size() int
+
+	// Encode writes the observation of a player to obs, which has Size values.
+	Encode(obs []float64, sim *theSimulation, player int)
+}
+
+// encodeShip writes the features of the ship of a player and returns where it is,
+// or where it is going to appear when it has none.
+func encodeShip(obs []float64, sim *theSimulation, player int) mathx.Vec2 {
+	i := sim.Ship(player)
+	if i < 0 {
+		pos, _ := sim.spawnPoint(player)
+		obs[0], obs[1], obs[2], obs[3], obs[4] = 0, 0, 0, 0, 0
+		return pos
+	}
+
+	ship := sim.At(i)
+	obs[0] = 1
+	obs[1] = math.Cos(ship.Rot)
+	obs[2] = math.Sin(ship.Rot)
+	obs[3] = ship.Vel[0] / envSpeedScale
+	obs[4] = ship.Vel[1] / envSpeedScale
+	return ship.Pos
+}
+
+// entityListEncoding lists the nearest entities relative to the ship, nearest first.
+// Each one is present, position, velocity, radius and whether it is an asteroid, debris, bullet or ship.
+type entityListEncoding struct {
+	Count int
+	near  []envNeighbour
+}
+
+type envNeighbour struct {
+	Entity *entity
+	Delta  mathx.Vec2
+	Dist   float64
+}
+
+const entityFeatures = 10
+
+func (enc *entityListEncoding) Size() int {
+	return shipFeatures + enc.Count*entityFeatures
+}
+
+func (enc *entityListEncoding) Encode(obs []float64, sim *theSimulation, player int) {
+	origin := encodeShip(obs, sim, player)
+	ship := sim.Ship(player)
+
+	enc.near = enc.near[:0]
+	for i := range sim.Entities {
+		e := sim.At(i)
+		if i == ship || e.Mask&flagDELETED != 0 {
+			continue
+		}
+		d := wrapDelta(origin, e.Pos, sim.Bounds)
+		enc.near = append(enc.near, envNeighbour{e, d, d.Len()})
+	}
+	sort.Slice(enc.near, func(i, j int) bool {
+		return enc.near[i].Dist < enc.near[j].Dist
+	})
+
+	size := sim.Bounds.Max.Sub(sim.Bounds.Min)
+	features := obs[shipFeatures:]
+	for i := range features {
+		features[i] = 0
+	}
+	for i := 0; i < enc.Count && i < len(enc.near); i++ {
+		n := enc.near[i]
+		f := features[i*entityFeatures:]
+		f[0] = 1
+		f[1] = 2 * n.Delta[0] / size[0]
+		f[2] = 2 * n.Delta[1] / size[1]
+		f[3] = n.Entity.Vel[0] / envSpeedScale
+		f[4] = n.Entity.Vel[1] / envSpeedScale
+		f[5] = n.Entity.Radius / envRadiusScale
+		f[6] = envFlag(n.Entity.Mask, flagASTEROID)
+		f[7] = envFlag(n.Entity.Mask, flagDEBRIS)
+		f[8] = envFlag(n.Entity.Mask, flagBULLET)
+		f[9] = envFlag(n.Entity.Mask, flagSPACESHIP)
+	}
+}
+
+func envFlag(mask, flag uint32) float64 {
+	if mask&flag != 0 {
+		return 1
+	}
+	return 0
+}
+
+// raySensorEncoding casts rays around the ship, starting straight ahead.
+// Each ray sees how close the nearest hazard along it is, from 1 when it touches the ship
+// to 0 when there is nothing within range, and how fast that hazard is closing in.
+type raySensorEncoding struct {
+	Rays  int
+	Range float64
+}
+
+func (enc *raySensorEncoding) Size() int {
+	return shipFeatures + 2*enc.Rays
+}
+
+func (enc *raySensorEncoding) Encode(obs []float64, sim *theSimulation, player int) {
+	origin := encodeShip(obs, sim, player)
+	heading := -mathx.Tau / 4
+	var vel mathx.Vec2
+	if i := sim.Ship(player); i >= 0 {
+		heading, vel = sim.At(i).Rot, sim.At(i).Vel
+	}
+
+	for ray := 0; ray < enc.Rays; ray++ {
+		dir := mathx.FromHeading(heading + mathx.Tau*float64(ray)/float64(enc.Rays))
+		nearest, closing := enc.Range, 0.0
+		for i := range sim.Entities {
+			e := sim.At(i)
+			if e.Mask&flagDELETED != 0 || !isHazard(sim, player, e) {
+				continue
+			}
+
+			d := wrapDelta(origin, e.Pos, sim.Bounds)
+			along := dot(d, dir)
+			across := dot(d, d) - along*along
+			if across > e.Radius*e.Radius {
+				continue
+			}
+
+			dist := math.Max(0, along-math.Sqrt(e.Radius*e.Radius-across))
+			if along+e.Radius >= 0 && dist < nearest {
+				nearest = dist
+				closing = -dot(e.Vel.Sub(vel), dir)
+			}
+		}
+
+		obs[shipFeatures+2*ray] = 1 - nearest/enc.Range
+		obs[shipFeatures+2*ray+1] = closing / envSpeedScale
+	}
+}
+
+// occupancyGridEncoding divides the field into cells around the ship, which is in the middle,
+// and marks the cells whose centre is covered by a hazard with 1.
+type occupancyGridEncoding struct {
+	Width, Height int
+}
+
+func (enc *occupancyGridEncoding) Size() int {
+	return shipFeatures + enc.Width*enc.Height
+}
+
+func (enc *occupancyGridEncoding) Encode(obs []float64, sim *theSimulation, player int) {
+	origin := encodeShip(obs, sim, player)
+	grid := obs[shipFeatures:]
+	for i := range grid {
+		grid[i] = 0
+	}
+
+	size := sim.Bounds.Max.Sub(sim.Bounds.Min)
+	cw, ch := size[0]/float64(enc.Width), size[1]/float64(enc.Height)
+	for i := range sim.Entities {
+		e := sim.At(i)
+		if e.Mask&flagDELETED != 0 || !isHazard(sim, player, e) {
+			continue
+		}
+
+		// cell coordinates with the ship in the middle of the grid
+		d := wrapDelta(origin, e.Pos, sim.Bounds)
+		cx := d[0]/cw + float64(enc.Width)/2
+		cy := d[1]/ch + float64(enc.Height)/2
+		rx, ry := e.Radius/cw, e.Radius/ch
+		for y := int(math.Floor(cy - ry)); y <= int(math.Ceil(cy+ry)); y++ {
+			for x := int(math.Floor(cx - rx)); x <= int(math.Ceil(cx+rx)); x++ {
+				dx, dy := (float64(x)+0.5-cx)*cw, (float64(y)+0.5-cy)*ch
+				if dx*dx+dy*dy > e.Radius*e.Radius {
+					continue
+				}
+				gx := (x%enc.Width + enc.Width) % enc.Width
+				gy := (y%enc.Height + enc.Height) % enc.Height
+				grid[gy*enc.Width+gx] = 1
+			}
+		}
+	}
+}
+
+// rewardWeights shape the reward of a player from what happened during a step.
+type rewardWeights struct {
+	Score float64 // per point scored
+	Death float64 // per ship lost
+	Level float64 // per level cleared, in a co-op game
+	Win   float64 // per round won, in a versus match
+	Frame float64 // per frame that the player has a ship
+}
+
+func defaultRewardWeights() rewardWeights {
+	return rewardWeights{
+		Score: 0.01,
+		Death: -1,
+		Level: 1,
+		Win:   1,
+	}
+}
+
+// gymInfo tells how the episode is going.
+type gymInfo struct {
+	Frame     int   `json:"frame"`
+	Level     int   `json:"level"`
+	Score     int   `json:"score"`
+	Scores    []int `json:"scores"`
+	Lives     []int `json:"lives"`
+	Wins      []int `json:"wins,omitempty"`
+	Truncated bool  `json:"truncated"` // the episode ran out of frames before the game was over
+}
+
+// gymEnv is a game that agents play one step at a time.
+type gymEnv struct {
+	Sim       *theSimulation
+	Players   int
+	Versus    bool
+	Rules     versusRules
+	Encoding  observationEncoding
+	Rewards   rewardWeights
+	FrameSkip int // frames that every action is repeated for
+	MaxFrames int // frames after which an episode is cut short, or 0 for no limit
+	Frame     int
+	started   bool // reset at least once, so that the game has the players that were asked for
+	last      []player
+	level     int
+}
+
+func newGymEnv(players int, encoding observationEncoding) *gymEnv {
+	return &gymEnv{
+		Sim:       newBareSimulation(0),
+		Players:   players,
+		Rules:     defaultVersusRules(),
+		Encoding:  encoding,
+		Rewards:   defaultRewardWeights(),
+		FrameSkip: 1,
+	}
+}
+
+// Reset starts a new episode and returns the observation of every player.
+func (env *gymEnv) Reset(seed int64) [][]float64 {
+	if env.Versus {
+		env.Sim.NewMatch(seed, env.Players, env.Rules)
+	} else {
+		env.Sim.NewGame(seed, env.Players)
+	}
+	env.Sim.Reset()
+	env.Frame = 0
+	env.started = true
+	env.last = append(env.last[:0], env.Sim.Players...)
+	env.level = env.Sim.Level
+	return env.observe()
+}
+
+// Step applies an action for every player, where missing actions do nothing,
+// and returns the observation and reward of every player, whether the episode is over and how it is going.
+func (env *gymEnv) Step(actions []gymAction) ([][]float64, []float64, bool, gymInfo) {
+	states := make([]controllerState, len(env.Sim.Players))
+	for i := range states {
+		if i < len(actions) {
+			states[i] = actions[i].controllerState()
+		}
+	}
+
+	rewards := make([]float64, len(env.Sim.Players))
+	done, truncated := netGameOver(env.Sim), false
+	for k := 0; k < env.FrameSkip && !done; k++ {
+		netAdvance(env.Sim, states)
+		env.Frame++
+		env.reward(rewards)
+		// the button presses only count on the first frame
+		for i := range states {
+			states[i].Pressed = 0
+		}
+
+		done = netGameOver(env.Sim)
+		if !done && env.MaxFrames > 0 && env.Frame >= env.MaxFrames {
+			done, truncated = true, true
+		}
+	}
+
+	info := env.info()
+	info.Truncated = truncated
+	return env.observe(), rewards, done, info
+}
+
+// reward adds what happened during the last frame to the rewards of the players.
+func (env *gymEnv) reward(rewards []float64) {
+	sim, w := env.Sim, env.Rewards
+	cleared := 0
+	if sim.Mode == modeCOOP && sim.Level > env.level {
+		cleared = sim.Level - env.level
+	}
+	env.level = sim.Level
+
+	for i, pl := range sim.Players {
+		last := env.last[i]
+		rewards[i] += w.Score*float64(pl.Score-last.Score) + w.Level*float64(cleared)
+		if pl.Lives < last.Lives {
+			rewards[i] += w.Death * float64(last.Lives-pl.Lives)
+		}
+		if pl.Wins > last.Wins {
+			rewards[i] += w.Win * float64(pl.Wins-last.Wins)
+		}
+		if sim.Ship(i) >= 0 {
+			rewards[i] += w.Frame
+		}
+	}
+	env.last = append(env.last[:0], sim.Players...)
+}
+
+func (env *gymEnv) observe() [][]float64 {
+	obs := make([][]float64, len(env.Sim.Players))
+	for i := range obs {
+		obs[i] = make([]float64, env.Encoding.Size())
+		env.Encoding.Encode(obs[i], env.Sim, i)
+	}
+	return obs
+}
+
+func (env *gymEnv) info() gymInfo {
+	info := gymInfo{
+		Frame: env.Frame,
+		Level: 1 + env.Sim.Level,
+		Score: env.Sim.Score,
+	}
+	for _, pl := range env.Sim.Players {
+		info.Scores = append(info.Scores, pl.Score)
+		info.Lives = append(info.Lives, pl.Lives)
+		if env.Sim.Mode == modeVERSUS {
+			info.Wins = append(info.Wins, pl.Wins)
+		}
+	}
+	return info
+}
+
+// The environments can be driven by programs in any language over a JSON protocol,
+// with one request per line on stdin and one response per line on stdout:
+//
+//	{"op": "spec"}
+//	{"op": "reset", "env": 0, "seed": 1}
+//	{"op": "step", "env": 0, "actions": [{"turn": -1, "thrust": 1, "fire": true}]}
+//
+// spec describes the environments and the others return the observations, rewards, done and info
+// of the environment that they address. Requests may be sent ahead of the responses, which are written
+// in the same order, so that a program can step all environments with a single round trip.
+type gymRequest struct {
+	Op      string      `json:"op"`
+	Env     int         `json:"env"`
+	Seed    int64       `json:"seed"`
+	Actions []gymAction `json:"actions"`
+}
+
+type gymResponse struct {
+	Env          int         `json:"env"`
+	Observations [][]float64 `json:"observations,omitempty"`
+	Rewards      []float64   `json:"rewards,omitempty"`
+	Done         bool        `json:"done"`
+	Info         *gymInfo    `json:"info,omitempty"`
+	Spec         *gymSpec    `json:"spec,omitempty"`
+	Error        string      `json:"error,omitempty"`
+}
+
+type gymSpec struct {
+	Envs            int    `json:"envs"`
+	Players         int    `json:"players"`
+	Versus          bool   `json:"versus"`
+	Encoding        string `json:"encoding"`
+	ObservationSize int    `json:"observation_size"`
+	FrameSkip       int    `json:"frame_skip"`
+	MaxFrames       int    `json:"max_frames"`
+}
+
+// serveGym answers the requests that are read from r until it runs out of them.
+func serveGym(envs []*gymEnv, spec gymSpec, r io.Reader, w io.Writer) error {
+	br := bufio.NewReader(r)
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+
+	for {
+		line, err := br.ReadBytes('\n')
+		if len(line) == 0 && err == io.EOF {
+			return bw.Flush()
+		} else if err != nil && err != io.EOF {
+			return err
+		}
+
+		var req gymRequest
+		var resp gymResponse
+		if err := json.Unmarshal(line, &req); err != nil {
+			resp.Error = err.Error()
+		} else {
+			resp = handleGym(envs, spec, req)
+		}
+
+		if err := enc.Encode(&resp); err != nil {
+			return err
+		}
+
+		// only wait for the program when it has nothing more to ask
+		if br.Buffered() == 0 {
+			if err := bw.Flush(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func handleGym(envs []*gymEnv, spec gymSpec, req gymRequest) gymResponse {
+	resp := gymResponse{Env: req.Env}
+	if req.Op == "spec" {
+		resp.Spec = &spec
+		return resp
+	} else if req.Env < 0 || req.Env >= len(envs) {
+		resp.Error = fmt.Sprintf("no environment %d", req.Env)
+		return resp
+	}
+
+	var info gymInfo
+	env := envs[req.Env]
+	switch req.Op {
+	case "reset":
+		resp.Observations = env.Reset(req.Seed)
+		info = env.info()
+	case "step":
+		if !env.started {
+			resp.Error = fmt.Sprintf("environment %d must be reset before it can step", req.Env)
+			return resp
+		}
+		resp.Observations, resp.Rewards, resp.Done, info = env.Step(req.Actions)
+	default:
+		resp.Error = fmt.Sprintf("unknown op %q", req.Op)
+		return resp
+	}
+	resp.Info = &info
+	return resp
+}
+
+// gymCommand serves the environment over stdin and stdout to a training script.
+func gymCommand(args []string, r io.Reader, w io.Writer) error {
+	fs := flag.NewFlagSet("gym", flag.ContinueOnError)
+	observe := fs.String("observe", "rays", "observation encoding: entities, rays or grid")
+	entities := fs.Int("entities", 16, "number of nearest entities that the entities encoding lists")
+	rays := fs.Int("rays", 16, "number of rays that the rays encoding casts")
+	rayRange := fs.Float64("range", 240, "distance that the rays can see")
+	gridWidth := fs.Int("grid-width", 32, "columns of the grid encoding")
+	gridHeight := fs.Int("grid-height", 18, "rows of the grid encoding")
+	count := fs.Int("envs", 1, "number of environments")
+	players := fs.Int("players", 1, "number of players that act every step")
+	versus := fs.Bool("versus", false, "let the players fight a versus match")
+	frameSkip := fs.Int("frameskip", 1, "frames that every action is repeated for")
+	maxFrames := fs.Int("max-frames", 0, "frames after which an episode is cut short, or 0 for no limit")
+	weights := defaultRewardWeights()
+	fs.Float64Var(&weights.Score, "reward-score", weights.Score, "reward per point scored")
+	fs.Float64Var(&weights.Death, "reward-death", weights.Death, "reward per ship lost")
+	fs.Float64Var(&weights.Level, "reward-level", weights.Level, "reward per level cleared")
+	fs.Float64Var(&weights.Win, "reward-win", weights.Win, "reward per round won in a versus match")
+	fs.Float64Var(&weights.Frame, "reward-frame", weights.Frame, "reward per frame with a ship")
+	if err := fs.Parse(args); err != nil {
+		return err
+	} else if *players < 1 || *players > maxPlayers {
+		return fmt.Errorf("the number of players must be between 1 and %d", maxPlayers)
+	} else if *versus && *players < 2 {
+		return fmt.Errorf("a versus match needs at least 2 players")
+	} else if *count < 1 {
+		return fmt.Errorf("the number of environments must be at least 1")
+	} else if *frameSkip < 1 {
+		return fmt.Errorf("the frame skip must be at least 1")
+	}
+
+	newEncoding := func() (observationEncoding, error) {
+		switch *observe {
+		case "entities":
+			if *entities < 1 {
+				return nil, fmt.Errorf("the number of entities must be at least 1")
+			}
+			return &entityListEncoding{Count: *entities}, nil
+		case "rays":
+			if *rays < 1 || *rayRange <= 0 {
+				return nil, fmt.Errorf("the rays encoding needs at least 1 ray and a positive range")
+			}
+			return &raySensorEncoding{Rays: *rays, Range: *rayRange}, nil
+		case "grid":
+			if *gridWidth < 1 || *gridHeight < 1 {
+				return nil, fmt.Errorf("the grid must be at least 1 by 1")
+			}
+			return &occupancyGridEncoding{Width: *gridWidth, Height: *gridHeight}, nil
+		}
+		return nil, fmt.Errorf("unknown observation encoding %q, choose one of entities, rays, grid", *observe)
+	}
+
+	// every environment has an encoding of its own because some of them keep a buffer
+	envs := make([]*gymEnv, *count)
+	for i := range envs {
+		encoding, err := newEncoding()
+		if err != nil {
+			return err
+		}
+		envs[i] = newGymEnv(*players, encoding)
+		envs[i].Versus = *versus
+		envs[i].Rewards = weights
+		envs[i].FrameSkip = *frameSkip
+		envs[i].MaxFrames = *maxFrames
+	}
+
+	return serveGym(envs, gymSpec{
+		Envs:            *count,
+		Players:         *players,
+		Versus:          *versus,
+		Encoding:        *observe,
+		ObservationSize: envs[0].Encoding.Size(),
+		FrameSkip:       *frameSkip,
+		MaxFrames:       *maxFrames,
+	}, r, w)
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestGymStepsOnlyAfterReset(t *testing.T) {
+	envs := []*gymEnv{newGymEnv(2, &raySensorEncoding{Rays: 8, Range: 200})}
+	requests := strings.Join([]string{
+		`{"op": "step", "env": 0, "actions": []}`,
+		`{"op": "reset", "env": 0, "seed": 1}`,
+		`{"op": "step", "env": 0, "actions": []}`,
+	}, "\n")
+
+	var out bytes.Buffer
+	if err := serveGym(envs, gymSpec{}, strings.NewReader(requests), &out); err != nil {
+		t.Fatal(err)
+	}
+
+	dec := json.NewDecoder(&out)
+	var resp [3]gymResponse
+	for i := range resp {
+		if err := dec.Decode(&resp[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if resp[0].Error == "" {
+		t.Fatal("stepped before the first reset")
+	} else if resp[2].Error != "" || len(resp[2].Observations) != 2 {
+		t.Fatalf("stepped with %d players: %q", len(resp[2].Observations), resp[2].Error)
+	}
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"math"
+	"math/big"
+	"sync"
+
+	"github.com/askeladdk/pancake/mathx"
+)
+
+// Floating point math gives slightly different results on different processors. Arm64 fuses a
+// multiplication and the addition after it into one instruction that rounds once instead of
+// twice, and the sines of the math package may differ in the last bit. That is enough for two
+// machines to play a different game from the same input. Sums and differences on their own are
+// rounded the same way everywhere. In fixed-point mode the simulation multiplies, divides, takes
+// roots and sines with integers instead, which give the same result on every processor.
+//
+// The entities keep their numbers in float64 so that saving, drawing and interpolation work the
+// same in both modes. The results of the integer math are whole numbers of 1/65536ths, which a
+// float64 holds exactly, so positions and velocities stay exact as well when they are added up.
+
+// fixed is a number with 16 bits of fraction.
+type fixed int64
+
+const (
+	fixedShift = 16
+	fixedOne   = 1 << fixedShift
+)
+
+// toFixed rounds a number to the nearest fixed-point number.
+func toFixed(v float64) fixed {
+	return fixed(math.Floor(v*fixedOne + 0.5))
+}
+
+func (f fixed) Float() float64 {
+	return float64(f) / fixedOne
+}
+
+func (f fixed) Mul(g fixed) fixed {
+	return f * g >> fixedShift
+}
+
+func (f fixed) Div(g fixed) fixed {
+	return (f << fixedShift) / g
+}
+
+// isqrt returns the square root of n rounded down.
+func isqrt(n uint64) uint64 {
+	bit := uint64(1) << 62
+	for bit > n {
+		bit >>= 2
+	}
+	var r uint64
+	for ; bit != 0; bit >>= 2 {
+		if n >= r+bit {
+			n -= r + bit
+			r = r>>1 + bit
+		} else {
+			r >>= 1
+		}
+	}
+	return r
+}
+
+type fixedVec [2]fixed
+
+func toFixedVec(v mathx.Vec2) fixedVec {
+	return fixedVec{toFixed(v[0]), toFixed(v[1])}
+}
+
+func (v fixedVec) Vec2() mathx.Vec2 {
+	return mathx.Vec2{v[0].Float(), v[1].Float()}
+}
+
+func (v fixedVec) Mul(k fixed) fixedVec {
+	return fixedVec{v[0].Mul(k), v[1].Mul(k)}
+}
+
+// Len2 returns the square of the length with 32 bits of fraction.
+func (v fixedVec) Len2() uint64 {
+	return uint64(v[0]*v[0] + v[1]*v[1])
+}
+
+func (v fixedVec) Len() fixed {
+	return fixed(isqrt(v.Len2()))
+}
+
+// Unit returns the vector scaled to a length of one, or the zero vector.
+func (v fixedVec) Unit() fixedVec {
+	n := v.Len()
+	if n == 0 {
+		return fixedVec{}
+	}
+	return fixedVec{v[0].Div(n), v[1].Div(n)}
+}
+
+// The sines are looked up in a table of a full turn and interpolated linearly.
+// The table is computed with big numbers, so that it is the same on every machine.
+const (
+	sinStepBits  = 12
+	sinSteps     = 1 << sinStepBits // entries per turn
+	sinFracBits  = 14               // bits of the angle between two entries
+	tauQ32       = 26986075409      // a full turn in radians with 32 bits of fraction
+	piDigits     = "3.14159265358979323846264338327950288419716939937510582097494459"
+	sinPrecision = 128
+)
+
+var (
+	sinTable     [sinSteps + 1]fixed
+	sinTableOnce sync.Once
+)
+
+func initSinTable() {
+	pi, _, _ := big.ParseFloat(piDigits, 10, sinPrecision, big.ToNearestEven)
+	var quarter [sinSteps/4 + 1]fixed
+	for i := range quarter {
+		x := new(big.Float).SetPrec(sinPrecision).SetInt64(int64(i))
+		x.Mul(x, pi)
+		x.Quo(x, big.NewFloat(sinSteps/2))
+		quarter[i] = bigSinFixed(x)
+	}
+	for i := range sinTable {
+		k := i % (sinSteps / 2)
+		v := quarter[minInt(k, sinSteps/2-k)]
+		if i >= sinSteps/2 {
+			v = -v
+		}
+		sinTable[i] = v
+	}
+}
+
+// bigSinFixed sums the Taylor series of the sine of a number between 0 and pi/2.
+func bigSinFixed(x *big.Float) fixed {
+	x2 := new(big.Float).SetPrec(sinPrecision).Mul(x, x)
+	term := new(big.Float).SetPrec(sinPrecision).Set(x)
+	sum := new(big.Float).SetPrec(sinPrecision).Set(x)
+	for n := int64(1); n < 40; n++ {
+		term.Mul(term, x2)
+		term.Quo(term, big.NewFloat(float64(2*n*(2*n+1))))
+		term.Neg(term)
+		sum.Add(sum, term)
+	}
+	sum.Mul(sum, big.NewFloat(fixedOne))
+	sum.Add(sum, big.NewFloat(0.5))
+	i, _ := sum.Int64() // truncates, and the sum is never negative
+	return fixed(i)
+}
+
+func lookupSin(i, frac int64) fixed {
+	i &= sinSteps - 1
+	a, b := sinTable[i], sinTable[i+1]
+	return a + (b-a)*fixed(frac)>>sinFracBits
+}
+
+// fixedHeading returns the unit vector of an angle in radians.
+func fixedHeading(angle fixed) fixedVec {
+	sinTableOnce.Do(initSinTable)
+	r := (int64(angle) << fixedShift) % tauQ32
+	if r < 0 {
+		r += tauQ32
+	}
+	t := (r << (sinStepBits + sinFracBits)) / tauQ32
+	i, frac := t>>sinFracBits, t&(1<<sinFracBits-1)
+	return fixedVec{lookupSin(i+sinSteps/4, frac), lookupSin(i, frac)}
+}
+
+// The simulation does the math that fixed-point mode changes through the methods below.
+// In floating point mode they are the plain operations.
+
+// random returns a number in the half-open interval [0, 1).
+func (s *theSimulation) random() float64 {
+	if s.Fixed {
+		return fixed(s.Rand.Uint64() >> (64 - fixedShift)).Float()
+	}
+	return s.Rand.Float64()
+}
+
+func (s *theSimulation) mul(a, b float64) float64 {
+	if s.Fixed {
+		return toFixed(a).Mul(toFixed(b)).Float()
+	}
+	return a * b
+}
+
+func (s *theSimulation) scale(v mathx.Vec2, k float64) mathx.Vec2 {
+	if s.Fixed {
+		return toFixedVec(v).Mul(toFixed(k)).Vec2()
+	}
+	return v.Mul(k)
+}
+
+func (s *theSimulation) heading(angle float64) mathx.Vec2 {
+	if s.Fixed {
+		return fixedHeading(toFixed(angle)).Vec2()
+	}
+	return mathx.FromHeading(angle)
+}
+
+func (s *theSimulation) length(v mathx.Vec2) float64 {
+	if s.Fixed {
+		return toFixedVec(v).Len().Float()
+	}
+	return v.Len()
+}
+
+func (s *theSimulation) unit(v mathx.Vec2) mathx.Vec2 {
+	if s.Fixed {
+		return toFixedVec(v).Unit().Vec2()
+	}
+	return v.Unit()
+}
+
+// overlaps reports whether two circles intersect.
+func (s *theSimulation) overlaps(c0, c1 mathx.Circle) bool {
+	if s.Fixed {
+		d := toFixedVec(c0.Center.Sub(c1.Center))
+		r := toFixed(c0.Radius + c1.Radius)
+		return d.Len2() < uint64(r*r)
+	}
+	return c0.IntersectsCircle(c1)
+}
+
+// wrap moves a point that left a rectangle back in on the other side.
+func (s *theSimulation) wrap(pos mathx.Vec2, r mathx.Rectangle) mathx.Vec2 {
+	if s.Fixed {
+		p, lo, hi := toFixedVec(pos), toFixedVec(r.Min), toFixedVec(r.Max)
+		for i := range p {
+			size := hi[i] - lo[i]
+			if p[i] = (p[i] - lo[i]) % size; p[i] < 0 {
+				p[i] += size
+			}
+			p[i] += lo[i]
+		}
+		return p.Vec2()
+	}
+	return pos.Wrap(r)
+}
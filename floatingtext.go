@@ -0,0 +1,78 @@
+//go:build !headless
+// +build !headless
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/askeladdk/pancake/mathx"
+	"github.com/askeladdk/pancake/text"
+)
+
+const (
+	floatingTextTime  = 1.0 // seconds until a floating text disappears
+	floatingTextSpeed = 24  // pixels per second that it rises
+)
+
+type floatingText struct {
+	Text *text.Text
+	Age  float64
+}
+
+// floatingTexts are short messages that rise and fade out where something happened.
+type floatingTexts struct {
+	Font  *text.Font
+	Items []floatingText
+	free  []*text.Text
+}
+
+func (ft *floatingTexts) Add(pos mathx.Vec2, format string, args ...interface{}) {
+	var t *text.Text
+	if n := len(ft.free); n > 0 {
+		t, ft.free = ft.free[n-1], ft.free[:n-1]
+	} else {
+		t = text.NewText(ft.Font)
+	}
+
+	t.Clear()
+	fmt.Fprintf(t, format, args...)
+	t.Pos = pos.Sub(mathx.Vec2{8, t.LineHeight / 2})
+	ft.Items = append(ft.Items, floatingText{Text: t})
+}
+
+// AddEvents adds the score changes that happened in the last simulation frame.
+func (ft *floatingTexts) AddEvents(events []simEvent) {
+	for _, ev := range events {
+		if ev.Code == eventSCORE && ev.Value != 0 {
+			ft.Add(ev.Pos, "%+d", ev.Value)
+		}
+	}
+}
+
+func (ft *floatingTexts) Clear() {
+	for _, item := range ft.Items {
+		ft.free = append(ft.free, item.Text)
+	}
+	ft.Items = ft.Items[:0]
+}
+
+func (ft *floatingTexts) Frame(deltaTime float64) {
+	items := ft.Items[:0]
+	for _, item := range ft.Items {
+		item.Age += deltaTime
+		if item.Age >= floatingTextTime {
+			ft.free = append(ft.free, item.Text)
+			continue
+		}
+		item.Text.Pos[1] -= floatingTextSpeed * deltaTime
+		items = append(items, item)
+	}
+	ft.Items = items
+}
+
+func (ft *floatingTexts) Draw(drawer *tintDrawer) {
+	for _, item := range ft.Items {
+		drawer.Draw(tintedDrawable{item.Text, opacity(1 - item.Age/floatingTextTime)})
+	}
+}
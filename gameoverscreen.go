@@ -1,31 +1,62 @@
+//go:build !headless
+// +build !headless
+
 package main
 
 import (
 	"fmt"
+	"path/filepath"
+	"time"
 
 	"github.com/askeladdk/pancake/input"
 
 	"github.com/askeladdk/pancake"
 	"github.com/askeladdk/pancake/graphics"
-	gl "github.com/askeladdk/pancake/graphics/opengl"
-	"github.com/askeladdk/pancake/graphics2d"
 	"github.com/askeladdk/pancake/text"
 )
 
 type gameOverScreen struct {
-	Sim        *theSimulation
+	*session
+	Res        *resources
 	Text       *text.Text
-	Drawer     *graphics2d.Drawer
+	Drawer     *tintDrawer
 	Shader     *graphics.ShaderProgram
 	Background staticImage
 	Title      staticImage
+	Rank       int
 	Restart    bool
+	Replay     bool
+}
+
+func newGameOverScreen(res *resources, sess *session) *gameOverScreen {
+	return &gameOverScreen{
+		Res:        res,
+		session:    sess,
+		Text:       text.NewText(res.Font16),
+		Drawer:     res.Drawer,
+		Shader:     res.Shader,
+		Background: res.Background,
+		Title:      res.GameOver,
+		Rank:       -1,
+	}
 }
 
 func (s *gameOverScreen) Begin() {
 	s.Restart = false
+	s.Replay = false
 	s.Text.Clear()
-	fmt.Fprintf(s.Text, "Final level: %d\nFinal score: %d\nPress Enter to restart or ESC to quit.", 1+s.Sim.Level, s.Sim.Score)
+	fmt.Fprintf(s.Text, "Final level: %d\nFinal score: %d\n", 1+s.Sim.Level, s.Sim.Score)
+	if s.Rank >= 0 {
+		fmt.Fprintf(s.Text, "New high score at rank %d!\n", 1+s.Rank)
+	}
+	s.Stats.Print(s.Text)
+	if s.RunLog != nil && s.RunLog.File != "" {
+		fmt.Fprintf(s.Text, "Run log: %s\n", filepath.Base(s.RunLog.File))
+	}
+	if s.canReplay() {
+		fmt.Fprintf(s.Text, "Press R to watch the replay.\n")
+	}
+	fmt.Fprintf(s.Text, "Press Enter to restart or ESC to quit.")
 }
 
 func (s *gameOverScreen) End() {}
@@ -36,22 +67,30 @@ func (s *gameOverScreen) Key(ev pancake.KeyEvent) error {
 		return pancake.ErrQuit
 	case input.KeyEnter:
 		s.Restart = true
+	case input.KeyR:
+		s.Replay = s.canReplay()
 	}
 	return nil
 }
 
-func (s *gameOverScreen) Frame(ev pancake.FrameEvent) (screen, error) {
-	if s.Restart {
-		s.Sim.Level = 0
-		s.Sim.Score = 0
-		return globalGameScreen, nil
+// canReplay reports whether the run was recorded.
+func (s *gameOverScreen) canReplay() bool {
+	return s.RunLog != nil && s.RunLog.Broken == ""
+}
+
+func (s *gameOverScreen) Frame(ev pancake.FrameEvent) (screenOp, error) {
+	if s.Replay {
+		s.Replay = false
+		return push(newPlaybackScreen(s.Res, s.session, &s.RunLog.Log)), nil
+	} else if s.Restart {
+		s.NewGame(time.Now().UnixNano(), len(s.Sim.Players))
+		return replace(newGameScreen(s.Res, s.session)), nil
 	}
 
-	return nil, nil
+	return screenOp{}, nil
 }
 
 func (s *gameOverScreen) Draw(ev pancake.DrawEvent) error {
-	gl.Clear(gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT)
 	s.Shader.Begin()
 	s.Drawer.Draw(s.Background)
 	s.Drawer.Draw(s.Title)
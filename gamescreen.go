@@ -1,3 +1,6 @@
+//go:build !headless
+// +build !headless
+
 package main
 
 import (
@@ -5,88 +8,297 @@ import (
 
 	"github.com/askeladdk/pancake"
 	"github.com/askeladdk/pancake/graphics"
-	gl "github.com/askeladdk/pancake/graphics/opengl"
-	"github.com/askeladdk/pancake/graphics2d"
-	"github.com/askeladdk/pancake/input"
 	"github.com/askeladdk/pancake/text"
+	"github.com/faiface/beep/speaker"
 )
 
+const quickSaveFile = "quicksave.dat"
+
 type gameScreen struct {
-	Sim        *theSimulation
+	*session
+	Res        *resources
 	Text       *text.Text
-	Drawer     *graphics2d.Drawer
+	Drawer     *tintDrawer
 	Shader     *graphics.ShaderProgram
 	Background staticImage
-	Keys       uint32
+	Floaters   floatingTexts
+	StartScore int
+	Start      []player // the players as they were when the level began
+	KillCam    killCamBuffer
+	Rewind     timeRewind
+	Rewinding  bool
+}
+
+func newGameScreen(res *resources, sess *session) *gameScreen {
+	return &gameScreen{
+		Res:        res,
+		session:    sess,
+		Floaters:   floatingTexts{Font: res.Font12},
+		Text:       text.NewText(res.Font16),
+		Drawer:     res.Drawer,
+		Shader:     res.Shader,
+		Background: res.Background,
+	}
 }
 
 func (g *gameScreen) Begin() {
-	g.Keys = 0
+	g.ResetControllers()
+	g.Floaters.Clear()
+	g.StartScore = g.Sim.Score
+	g.Start = append(g.Start[:0], g.Sim.Players...)
+	g.Sim.Reset()
+	g.Achievements.BeginLevel()
+	g.KillCam.Clear()
+	g.Rewind.Clear()
+	g.keepRewind()
+}
+
+// Restart starts the current level over with the scores and lives it began with.
+func (g *gameScreen) Restart() {
+	g.Sim.Score = g.StartScore
+	g.Sim.Players = append(g.Sim.Players[:0], g.Start...)
 	g.Sim.Reset()
+	g.Stats.LevelTime = 0
+	g.Achievements.BeginLevel()
+	g.Floaters.Clear()
+	g.KillCam.Clear()
+	g.Rewind.Clear()
+	g.keepRewind()
+	if g.RunLog != nil {
+		g.RunLog.Restart()
+	}
 }
 
-func (g *gameScreen) End() {}
+func (g *gameScreen) End() {
+	g.reverseMusic(false)
+}
 
+// Key feeds the keyboard to the controllers, which are the only things that interpret it.
+// Later players get the first pick so that player 1 can keep keys that are bound by both.
 func (g *gameScreen) Key(ev pancake.KeyEvent) error {
-	switch ev.Key {
-	case input.KeyEscape:
-		return pancake.ErrQuit
-	case input.KeyA:
-		fallthrough
-	case input.KeyLeft:
-		g.Keys = toggleFlag(g.Keys, 1, ev.Flags.Down())
-	case input.KeyD:
-		fallthrough
-	case input.KeyRight:
-		g.Keys = toggleFlag(g.Keys, 2, ev.Flags.Down())
-	case input.KeyW:
-		fallthrough
-	case input.KeyUp:
-		g.Keys = toggleFlag(g.Keys, 4, ev.Flags.Down())
-	case input.KeyP:
-		if ev.Flags.Pressed() {
-			g.Sim.SpawnAsteroid()
-		}
-	case input.KeySpace:
-		if ev.Flags.Pressed() {
-			g.Sim.Action(shipID, actionFire, 0)
+	active := g.Active()
+	for i := len(active) - 1; i >= 0; i-- {
+		if kr, ok := active[i].(keyReceiver); ok && kr.Key(ev) {
+			break
 		}
 	}
 	return nil
 }
 
-func (g *gameScreen) Frame(ev pancake.FrameEvent) (screen, error) {
+func (g *gameScreen) quickSave() {
+	if filename, err := userFilePath(quickSaveFile); err != nil {
+		fmt.Println(err)
+	} else if err := g.Sim.SaveFile(filename); err != nil {
+		fmt.Println(err)
+	}
+}
+
+func (g *gameScreen) quickLoad() {
+	if filename, err := userFilePath(quickSaveFile); err != nil {
+		fmt.Println(err)
+	} else if err := g.LoadFile(filename); err != nil {
+		fmt.Println(err)
+	} else {
+		g.StartScore = g.Sim.Score
+		g.Start = append(g.Start[:0], g.Sim.Players...)
+		g.Floaters.Clear()
+		g.KillCam.Clear()
+		g.Rewind.Clear()
+		g.keepRewind()
+		if g.RunLog != nil {
+			g.RunLog.Break("a quick save was loaded")
+		}
+	}
+}
+
+func (g *gameScreen) Frame(ev pancake.FrameEvent) (screenOp, error) {
+	if op, over := g.checkState(); over {
+		return op, nil
+	}
+
+	active := g.Active()
+	states := make([]controllerState, len(active))
+	var held, pressed controllerButtons
+	for i, c := range active {
+		states[i] = c.Poll()
+		held |= states[i].Held
+		pressed |= states[i].Pressed
+	}
+
+	rewinding := g.Res.Rewind && held&buttonRewind != 0 && pressed&buttonPause == 0 && g.rewind()
+	g.reverseMusic(rewinding)
+	if rewinding {
+		g.Floaters.Frame(ev.DeltaTime)
+		g.printStatus()
+		g.printRewind()
+		return screenOp{}, nil
+	}
+
+	if pressed&buttonPause != 0 {
+		// key releases go to the pause screen, so forget what was held down
+		g.ResetControllers()
+		return push(newPauseScreen(g.Res, g)).With(effectDISSOLVE, pauseFadeTime), nil
+	}
+
+	if pressed&buttonQuickSave != 0 {
+		g.quickSave()
+	}
+
+	if pressed&buttonQuickLoad != 0 {
+		// the loaded game might not have a ship to control
+		g.quickLoad()
+		return screenOp{}, nil
+	}
+
+	if pressed&buttonSpawnAsteroid != 0 {
+		g.Sim.SpawnAsteroid()
+		if g.RunLog != nil {
+			g.RunLog.Break("an asteroid was spawned by hand")
+		}
+	}
+
+	g.step(states, ev.DeltaTime)
+	g.printStatus()
+	g.printRewind()
+	return screenOp{}, nil
+}
+
+// rewind takes the game back by one frame and plays the sounds of that frame backwards.
+// The statistics go back with the game, but a run in which time was rewound
+// unlocks no achievements and does not enter the high score table.
+func (g *gameScreen) rewind() bool {
+	events, ok := g.Rewind.Back(g.Sim, &g.Stats)
+	if !ok {
+		return false
+	}
+	g.Rewound = true
+	g.Floaters.Clear()
+	for _, e := range events {
+		if snd, ok := eventSounds[e.Code]; ok {
+			g.Sim.PlaySoundReversed(snd)
+		}
+	}
+	g.KillCam.Back()
+	if g.RunLog != nil {
+		g.RunLog.Break("time was rewound")
+	}
+	return true
+}
+
+// keepRewind puts the last frame into the rewind history when rewinding is turned on.
+// The history is forgotten while it is off, so that it never skips over frames.
+func (g *gameScreen) keepRewind() {
+	if g.Res.Rewind {
+		g.Rewind.Put(g.Sim, &g.Stats)
+	} else if g.Rewind.Count > 0 {
+		g.Rewind.Clear()
+	}
+}
+
+// reverseMusic plays the music backwards while time is rewound.
+func (g *gameScreen) reverseMusic(reverse bool) {
+	if g.Rewinding == reverse || g.Res.MusicLoop == nil {
+		return
+	}
+	g.Rewinding = reverse
+	speaker.Lock()
+	g.Res.MusicLoop.Reverse = reverse
+	speaker.Unlock()
+}
+
+// checkState leaves the game screen when the level, round or game is over.
+func (g *gameScreen) checkState() (screenOp, bool) {
 	switch g.Sim.State {
 	case stateGAMEOVER:
-		return globalGameOverScreen, nil
+		g.Stats.EndLevel(g.Sim)
+		if err := exportStats(&g.Stats); err != nil {
+			fmt.Println(err)
+		}
+		if g.RunLog != nil {
+			if filename, err := g.RunLog.Save(g.Sim); err != nil {
+				fmt.Println(err)
+			} else {
+				fmt.Println("run log saved to", filename)
+			}
+		}
+		var next screen = newGameOverScreen(g.Res, g.session)
+		if !g.Rewound && g.Res.HighScores.Qualifies(g.Sim.Score) {
+			next = newNameEntryScreen(g.Res, g.session)
+		}
+		return replace(newKillCamScreen(g.Res, g.session, g.KillCam.States(), g.Sim.Events, next)), true
 	case stateNEXTLEVEL:
-		return globalNextScreen, nil
+		g.Stats.EndLevel(g.Sim)
+		return replace(newNextScreen(g.Res, g.session)), true
+	case stateROUNDOVER:
+		return replace(newRoundEndScreen(g.Res, g.session)), true
 	}
+	return screenOp{}, false
+}
 
-	if g.Keys&3 == 1 {
-		g.Sim.Action(shipID, actionTurn, -1)
-	} else if g.Keys&3 == 2 {
-		g.Sim.Action(shipID, actionTurn, +1)
+// step applies the controller state of every player and advances the simulation by one frame.
+func (g *gameScreen) step(states []controllerState, deltaTime float64) {
+	for i, cs := range states {
+		cs.Apply(g.Sim, i)
 	}
 
-	if g.Keys&4 != 0 {
-		g.Sim.Action(shipID, actionForward, 1)
+	if g.RunLog != nil {
+		g.RunLog.Record(g.Sim, deltaTime)
 	}
+	g.KillCam.Put(g.Sim)
+	g.Sim.Frame(deltaTime)
+	g.keepRewind()
+	g.observe(g.Sim, deltaTime)
+}
 
-	g.Sim.Frame(ev.DeltaTime)
+// observe lets everything that watches the game see what happened during the last frame of sim,
+// which is the game itself or, in a network game, the game as it was after the last tick that is final.
+func (g *gameScreen) observe(sim *theSimulation, deltaTime float64) {
+	g.Stats.Observe(sim, deltaTime)
+	if sim.Mode != modeVERSUS && !g.Rewound {
+		g.Achievements.Observe(sim, deltaTime)
+		g.Res.Toasts.Add(g.Achievements.PopUnlocked()...)
+	}
+	g.Floaters.Frame(deltaTime)
+	g.Floaters.AddEvents(sim.Events)
+	if g.Res.Feed != nil {
+		g.Res.Feed.Publish(sim)
+	}
+}
 
+func (g *gameScreen) printStatus() {
 	g.Text.Clear()
+	if g.Sim.Mode == modeVERSUS {
+		fmt.Fprintf(g.Text, "Round: %d", 1+g.Sim.Level)
+		for i, p := range g.Sim.Players {
+			fmt.Fprintf(g.Text, "\nP%d: %d wins  Ships: %d", 1+i, p.Wins, p.Lives)
+		}
+		return
+	}
+
 	fmt.Fprintf(g.Text, "Level: %d\nScore: %d", 1+g.Sim.Level, g.Sim.Score)
+	if len(g.Sim.Players) > 1 {
+		for i, p := range g.Sim.Players {
+			fmt.Fprintf(g.Text, "\nP%d: %d  Ships: %d", 1+i, p.Score, p.Lives)
+		}
+	}
+	if g.Sim.Multiplier > 1 {
+		fmt.Fprintf(g.Text, "\nCombo: x%d", g.Sim.Multiplier)
+	}
+}
 
-	return nil, nil
+// printRewind shows how much time can be rewound.
+func (g *gameScreen) printRewind() {
+	if g.Res.Rewind {
+		fmt.Fprintf(g.Text, "\nRewind: %d%%", int(100*g.Rewind.Meter/rewindMeter))
+	}
 }
 
 func (g *gameScreen) Draw(ev pancake.DrawEvent) error {
-	gl.Clear(gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT)
 	g.Shader.Begin()
 	g.Sim.Alpha = ev.Alpha
 	g.Drawer.Draw(g.Background)
 	g.Drawer.Draw(g.Sim)
+	g.Floaters.Draw(g.Drawer)
 	g.Drawer.Draw(g.Text)
 	g.Shader.End()
 	return nil
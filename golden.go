@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Fixed-point mode must play exactly the same game on every processor. The golden command plays
+// a few games in fixed-point mode and compares the hashes of their states with the ones that were
+// written down in a file on another machine. TestGolden does the same as part of the tests, so running
+// the tests on another processor, or under an emulator such as qemu-user, checks that it plays the same there:
+//
+//	make golden-arm64
+
+const (
+	goldenFile     = "testdata/fixedpoint.golden"
+	goldenFrames   = 3600 // frame limit of a game, one minute
+	goldenInterval = 60   // frames between the hashes that are written down
+)
+
+// goldenGame is one of the games that the golden command plays.
+type goldenGame struct {
+	Name    string
+	Seed    int64
+	Players int
+	Versus  bool
+	Policy  inputPolicy
+}
+
+var goldenGames = []goldenGame{
+	{"spin", 1, 1, false, inputPolicies["spin"]},
+	{"random", 4, 1, false, inputPolicies["random"]},
+	{"jump", 9, 1, false, jumpPolicy},
+	{"coop", 3, 2, false, jumpPolicy},
+	{"versus", 3, 2, true, jumpPolicy},
+}
+
+// jumpPolicy mashes the controls like the random policy and jumps through hyperspace now and then.
+func jumpPolicy(sim *theSimulation, player int, seed int64) controller {
+	c := randomPolicy(sim, player, seed)
+	return &scriptedController{Script: func(frame int) controllerState {
+		cs := c.Poll()
+		if frame%97 == 0 {
+			cs.Pressed |= buttonHyperspace
+		}
+		return cs
+	}}
+}
+
+// goldenTrace plays a game in fixed-point mode and returns every goldenInterval-th hash
+// and the last one, one per line.
+func goldenTrace(gg goldenGame) ([]string, error) {
+	g := newPolicyDesyncGame(gg.Seed, gg.Players, gg.Versus, true, gg.Policy)
+	hashes, _, err := g.Run(goldenFrames)
+	if err != nil {
+		return nil, err
+	} else if g.A.TickHash != g.B.TickHash {
+		return nil, fmt.Errorf("%s: the simulation is not deterministic at tick %d", gg.Name, len(hashes))
+	}
+
+	var lines []string
+	for i, h := range hashes {
+		if tick := i + 1; tick%goldenInterval == 0 || tick == len(hashes) {
+			lines = append(lines, fmt.Sprintf("%s %d %016x", gg.Name, tick, h))
+		}
+	}
+	return lines, nil
+}
+
+func readGoldenFile(filename string) ([]string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var lines []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		if line := strings.TrimSpace(sc.Text()); line != "" && !strings.HasPrefix(line, "#") {
+			lines = append(lines, line)
+		}
+	}
+	return lines, sc.Err()
+}
+
+// goldenCommand checks that fixed-point mode plays the same games as the machine that wrote the golden file.
+func goldenCommand(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("golden", flag.ContinueOnError)
+	filename := fs.String("file", goldenFile, "file with the hashes of the golden games")
+	write := fs.Bool("write", false, "write the hashes to the file instead of comparing them")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var lines []string
+	for _, gg := range goldenGames {
+		trace, err := goldenTrace(gg)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "%s\n", trace[len(trace)-1])
+		lines = append(lines, trace...)
+	}
+
+	if *write {
+		var sb strings.Builder
+		sb.WriteString("# hashes of the golden games in fixed-point mode, written by the golden command\n")
+		sb.WriteString("# game tick hash\n")
+		for _, line := range lines {
+			sb.WriteString(line + "\n")
+		}
+		return os.WriteFile(*filename, []byte(sb.String()), 0644)
+	}
+
+	golden, err := readGoldenFile(*filename)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < minInt(len(lines), len(golden)); i++ {
+		if lines[i] != golden[i] {
+			return fmt.Errorf("the games differ from %s:\n  got  %s\n  want %s", *filename, lines[i], golden[i])
+		}
+	}
+	if len(lines) != len(golden) {
+		return fmt.Errorf("%d hashes were computed here but %s has %d", len(lines), *filename, len(golden))
+	}
+	fmt.Fprintf(out, "all %d hashes match %s\n", len(lines), *filename)
+	return nil
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestGolden plays the golden games in fixed-point mode and compares them with testdata/fixedpoint.golden,
+// which was written on amd64. Running the tests on another processor checks that it plays the same games.
+func TestGolden(t *testing.T) {
+	golden, err := readGoldenFile(goldenFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, gg := range goldenGames {
+		gg := gg
+		t.Run(gg.Name, func(t *testing.T) {
+			var want []string
+			for _, line := range golden {
+				if strings.HasPrefix(line, gg.Name+" ") {
+					want = append(want, line)
+				}
+			}
+
+			got, err := goldenTrace(gg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < minInt(len(got), len(want)); i++ {
+				if got[i] != want[i] {
+					t.Fatalf("got %s, want %s", got[i], want[i])
+				}
+			}
+			if len(got) != len(want) {
+				t.Fatalf("%d hashes were computed but the golden file has %d", len(got), len(want))
+			}
+		})
+	}
+}
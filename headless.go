@@ -0,0 +1,69 @@
+//go:build headless
+// +build headless
+
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// The headless build has no window, graphics or sound and runs on any machine:
+//
+//	go build -tags headless -o asteroids-headless
+//
+// It offers the parts of the game that do not need to be seen as commands.
+type headlessCommand struct {
+	Name  string
+	Usage string
+	Run   func(args []string) error
+}
+
+var headlessCommands = []headlessCommand{
+	{"server", "run a game server", func(args []string) error {
+		return serverCommand(args, os.Stdout)
+	}},
+	{"bot", "let the autopilot play games", func(args []string) error {
+		return botCommand(args, os.Stdout)
+	}},
+	{"bench", "play a batch of games and report the scores and speed", func(args []string) error {
+		return benchCommand(args, os.Stdout)
+	}},
+	{"verify", "play run logs again to check their scores", func(args []string) error {
+		return verifyCommand(args, os.Stdout)
+	}},
+	{"desync", "play a game twice side by side and show where it diverges", func(args []string) error {
+		return desyncCommand(args, os.Stdout)
+	}},
+	{"golden", "check that fixed-point mode plays the same games as on other machines", func(args []string) error {
+		return goldenCommand(args, os.Stdout)
+	}},
+	{"gym", "serve a training environment over stdin and stdout", func(args []string) error {
+		return gymCommand(args, os.Stdin, os.Stdout)
+	}},
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: %s <command> [flags]\n\ncommands:\n", os.Args[0])
+	for _, c := range headlessCommands {
+		fmt.Fprintf(os.Stderr, "  %-8s %s\n", c.Name, c.Usage)
+	}
+	os.Exit(2)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	for _, c := range headlessCommands {
+		if c.Name == os.Args[1] {
+			if err := c.Run(os.Args[2:]); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+	usage()
+}
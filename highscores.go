@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+)
+
+const (
+	highScoresFile    = "highscores.json"
+	highScoresVersion = 1
+	maxHighScores     = 10
+	maxNameLength     = 12
+)
+
+type highScore struct {
+	Name  string    `json:"name"`
+	Score int       `json:"score"`
+	Level int       `json:"level"`
+	Date  time.Time `json:"date"`
+	Seed  int64     `json:"seed"`
+}
+
+// highScoreTable holds the best scores in descending order.
+type highScoreTable struct {
+	Scores []highScore
+}
+
+// Qualifies reports whether a score is good enough to enter the table.
+func (t *highScoreTable) Qualifies(score int) bool {
+	if score <= 0 {
+		return false
+	}
+	return len(t.Scores) < maxHighScores || score > t.Scores[len(t.Scores)-1].Score
+}
+
+// Insert adds a score to the table and returns its rank, or -1 if it did not make it.
+// Equal scores are ranked by who got there first.
+func (t *highScoreTable) Insert(hs highScore) int {
+	rank := sort.Search(len(t.Scores), func(i int) bool {
+		return t.Scores[i].Score < hs.Score
+	})
+
+	if rank >= maxHighScores {
+		return -1
+	}
+
+	t.Scores = append(t.Scores, highScore{})
+	copy(t.Scores[rank+1:], t.Scores[rank:])
+	t.Scores[rank] = hs
+
+	if len(t.Scores) > maxHighScores {
+		t.Scores = t.Scores[:maxHighScores]
+	}
+	return rank
+}
+
+type highScoresJSON struct {
+	Version int         `json:"version"`
+	Scores  []highScore `json:"scores"`
+}
+
+func (t *highScoreTable) MarshalJSON() ([]byte, error) {
+	return json.Marshal(highScoresJSON{
+		Version: highScoresVersion,
+		Scores:  t.Scores,
+	})
+}
+
+// UnmarshalJSON decodes the table and drops any entries that make no sense,
+// so that a file edited by hand cannot break the game.
+func (t *highScoreTable) UnmarshalJSON(data []byte) error {
+	var v highScoresJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	} else if v.Version != highScoresVersion {
+		return fmt.Errorf("unsupported high score version %d", v.Version)
+	}
+
+	var table highScoreTable
+	for _, hs := range v.Scores {
+		if hs.Score <= 0 || hs.Level < 0 {
+			continue
+		}
+		hs.Name = sanitizeName(hs.Name)
+		table.Insert(hs)
+	}
+
+	*t = table
+	return nil
+}
+
+// sanitizeName keeps the characters that the font can draw and truncates long names.
+func sanitizeName(name string) string {
+	var b []byte
+	for i := 0; i < len(name) && len(b) < maxNameLength; i++ {
+		if c := name[i]; c >= ' ' && c <= '~' {
+			b = append(b, c)
+		}
+	}
+	if len(b) == 0 {
+		return "Player"
+	}
+	return string(b)
+}
+
+// loadHighScores reads the high score table from disk.
+// A missing file is not an error, and an unreadable one yields an empty table and the reason why.
+func loadHighScores() (highScoreTable, error) {
+	var table highScoreTable
+	filename, err := userFilePath(highScoresFile)
+	if err != nil {
+		return table, err
+	}
+
+	data, err := os.ReadFile(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return table, nil
+	} else if err != nil {
+		return table, err
+	}
+
+	if err := json.Unmarshal(data, &table); err != nil {
+		return highScoreTable{}, fmt.Errorf("%s: %v", filename, err)
+	}
+	return table, nil
+}
+
+func saveHighScores(t *highScoreTable) error {
+	filename, err := userFilePath(highScoresFile)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(t, "", "\t")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filename, data)
+}
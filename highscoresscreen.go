@@ -0,0 +1,70 @@
+//go:build !headless
+// +build !headless
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/askeladdk/pancake"
+	"github.com/askeladdk/pancake/graphics"
+	"github.com/askeladdk/pancake/mathx"
+	"github.com/askeladdk/pancake/text"
+)
+
+type highScoresScreen struct {
+	Table      *highScoreTable
+	Text       *text.Text
+	Drawer     *tintDrawer
+	Shader     *graphics.ShaderProgram
+	Background staticImage
+	Done       bool
+}
+
+func newHighScoresScreen(res *resources) *highScoresScreen {
+	t := text.NewText(res.Font16)
+	t.Pos = mathx.Vec2{res.Midscreen()[0] - 192, 32}
+	return &highScoresScreen{
+		Table:      res.HighScores,
+		Text:       t,
+		Drawer:     res.Drawer,
+		Shader:     res.Shader,
+		Background: res.Background,
+	}
+}
+
+func (s *highScoresScreen) Begin() {
+	s.Done = false
+	s.Text.Clear()
+	fmt.Fprintf(s.Text, "High scores\n\n")
+	if len(s.Table.Scores) == 0 {
+		fmt.Fprintf(s.Text, "No scores yet.\n")
+	}
+	for i, hs := range s.Table.Scores {
+		fmt.Fprintf(s.Text, "%2d. %-12s %8d  level %-3d %s\n",
+			1+i, hs.Name, hs.Score, hs.Level, hs.Date.Format("2006-01-02"))
+	}
+	fmt.Fprintf(s.Text, "\nPress any key to return.")
+}
+
+func (s *highScoresScreen) End() {}
+
+func (s *highScoresScreen) Key(ev pancake.KeyEvent) error {
+	s.Done = s.Done || ev.Flags.Pressed()
+	return nil
+}
+
+func (s *highScoresScreen) Frame(ev pancake.FrameEvent) (screenOp, error) {
+	if s.Done {
+		return pop(), nil
+	}
+	return screenOp{}, nil
+}
+
+func (s *highScoresScreen) Draw(ev pancake.DrawEvent) error {
+	s.Shader.Begin()
+	s.Drawer.Draw(s.Background)
+	s.Drawer.Draw(s.Text)
+	s.Shader.End()
+	return nil
+}
@@ -0,0 +1,92 @@
+//go:build !headless
+// +build !headless
+
+package main
+
+import (
+	"github.com/askeladdk/pancake"
+	"github.com/askeladdk/pancake/input"
+)
+
+// keyReceiver is implemented by controllers that are fed by the keyboard.
+// Key reports whether the controller used the key,
+// so that players sharing a keyboard do not react to each other's keys.
+type keyReceiver interface {
+	Key(pancake.KeyEvent) bool
+}
+
+// keyboardController turns key events into controller state through the key bindings.
+type keyboardController struct {
+	Bindings *keyBindings
+	held     uint32
+	pressed  controllerButtons
+}
+
+func newKeyboardController(bindings *keyBindings) *keyboardController {
+	return &keyboardController{Bindings: bindings}
+}
+
+func (kc *keyboardController) Key(ev pancake.KeyEvent) bool {
+	if a, ok := kc.Bindings.Lookup(ev.Key); ok {
+		switch a {
+		case bindThrust, bindTurnLeft, bindTurnRight, bindRewind:
+			kc.held = toggleFlag(kc.held, 1<<a, ev.Flags.Down())
+		case bindFire:
+			kc.press(buttonFire, ev)
+		case bindHyperspace:
+			kc.press(buttonHyperspace, ev)
+		case bindPause:
+			kc.press(buttonPause, ev)
+		}
+		return true
+	}
+
+	// the reserved keys, and Escape so that the game can always be paused
+	switch ev.Key {
+	case input.KeyEscape:
+		kc.press(buttonPause, ev)
+	case input.KeyP:
+		kc.press(buttonSpawnAsteroid, ev)
+	case input.KeyF5:
+		kc.press(buttonQuickSave, ev)
+	case input.KeyF9:
+		kc.press(buttonQuickLoad, ev)
+	default:
+		return false
+	}
+	return true
+}
+
+func (kc *keyboardController) press(b controllerButtons, ev pancake.KeyEvent) {
+	if ev.Flags.Pressed() {
+		kc.pressed |= b
+	}
+}
+
+func (kc *keyboardController) Poll() controllerState {
+	var cs controllerState
+
+	const turnKeys = 1<<bindTurnLeft | 1<<bindTurnRight
+	if kc.held&turnKeys == 1<<bindTurnLeft {
+		cs.Turn = -1
+	} else if kc.held&turnKeys == 1<<bindTurnRight {
+		cs.Turn = +1
+	}
+
+	if kc.held&(1<<bindThrust) != 0 {
+		cs.Thrust = 1
+	}
+
+	if kc.held&(1<<bindRewind) != 0 {
+		cs.Held |= buttonRewind
+	}
+
+	cs.Pressed = kc.pressed
+	kc.pressed = 0
+	return cs
+}
+
+func (kc *keyboardController) Reset() {
+	kc.held = 0
+	kc.pressed = 0
+}
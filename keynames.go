@@ -0,0 +1,82 @@
+//go:build !headless
+// +build !headless
+
+package main
+
+import "github.com/askeladdk/pancake/input"
+
+// keyNames are the keys that can be bound to game actions, with their names in the config file.
+var keyNames = []struct {
+	Name string
+	Key  input.Key
+}{
+	{"A", input.KeyA},
+	{"B", input.KeyB},
+	{"C", input.KeyC},
+	{"D", input.KeyD},
+	{"E", input.KeyE},
+	{"F", input.KeyF},
+	{"G", input.KeyG},
+	{"H", input.KeyH},
+	{"I", input.KeyI},
+	{"J", input.KeyJ},
+	{"K", input.KeyK},
+	{"L", input.KeyL},
+	{"M", input.KeyM},
+	{"N", input.KeyN},
+	{"O", input.KeyO},
+	{"P", input.KeyP},
+	{"Q", input.KeyQ},
+	{"R", input.KeyR},
+	{"S", input.KeyS},
+	{"T", input.KeyT},
+	{"U", input.KeyU},
+	{"V", input.KeyV},
+	{"W", input.KeyW},
+	{"X", input.KeyX},
+	{"Y", input.KeyY},
+	{"Z", input.KeyZ},
+	{"0", input.Key0},
+	{"1", input.Key1},
+	{"2", input.Key2},
+	{"3", input.Key3},
+	{"4", input.Key4},
+	{"5", input.Key5},
+	{"6", input.Key6},
+	{"7", input.Key7},
+	{"8", input.Key8},
+	{"9", input.Key9},
+	{"Space", input.KeySpace},
+	{"Enter", input.KeyEnter},
+	{"Escape", input.KeyEscape},
+	{"Tab", input.KeyTab},
+	{"Backspace", input.KeyBackspace},
+	{"Up", input.KeyUp},
+	{"Down", input.KeyDown},
+	{"Left", input.KeyLeft},
+	{"Right", input.KeyRight},
+	{"LeftShift", input.KeyLeftShift},
+	{"RightShift", input.KeyRightShift},
+	{"LeftControl", input.KeyLeftControl},
+	{"RightControl", input.KeyRightControl},
+	{"LeftAlt", input.KeyLeftAlt},
+	{"RightAlt", input.KeyRightAlt},
+}
+
+func keyName(key input.Key) (string, bool) {
+	for _, kn := range keyNames {
+		if kn.Key == key {
+			return kn.Name, true
+		}
+	}
+	return "", false
+}
+
+func keyByName(name string) (input.Key, bool) {
+	for _, kn := range keyNames {
+		if kn.Name == name {
+			return kn.Key, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,154 @@
+package main
+
+import "github.com/askeladdk/pancake/mathx"
+
+// The kill cam shows the death that ended the game again in slow motion. The game screen keeps
+// the states of the last few seconds in a ring, and the kill cam works out from the death
+// which ship to follow and what hit it by going back through the states.
+
+const (
+	killCamFrames    = 120 // states that are kept, two seconds
+	killCamTrackSlop = 1   // pixels that an entity may be away from where it was last seen
+)
+
+// killCamBuffer keeps the game states before the last few frames.
+type killCamBuffer struct {
+	Ring  [killCamFrames][]byte
+	Head  int // states put
+	Count int // states in the ring
+}
+
+func (b *killCamBuffer) Clear() {
+	b.Head, b.Count = 0, 0
+}
+
+// Put saves the state of the game before a frame is simulated.
+func (b *killCamBuffer) Put(sim *theSimulation) {
+	state, err := sim.MarshalBinary()
+	if err != nil {
+		return
+	}
+	b.Ring[b.Head%killCamFrames] = state
+	b.Head++
+	b.Count = minInt(b.Count+1, killCamFrames)
+}
+
+// Back forgets the state before the last frame, when that frame is rewound.
+func (b *killCamBuffer) Back() {
+	if b.Count > 0 {
+		b.Head--
+		b.Count--
+	}
+}
+
+// States returns the saved states from the oldest to the newest.
+func (b *killCamBuffer) States() [][]byte {
+	states := make([][]byte, 0, b.Count)
+	for i := b.Head - b.Count; i < b.Head; i++ {
+		states = append(states, b.Ring[i%killCamFrames])
+	}
+	return states
+}
+
+// killCam is the end of a game that is shown again up to the death that ended it.
+type killCam struct {
+	States  [][]byte
+	Death   simEvent
+	Player  int   // the player whose ship died
+	Ships   []int // index of the entity of the ship in every state, or -1
+	Killers []int // index of the entity that hit the ship in every state, or -1
+}
+
+// newKillCam follows the ship that died and what hit it back through the states,
+// using sim to decode them. The last state is the one before the fatal frame.
+// It returns nil when there is nothing to show.
+func newKillCam(states [][]byte, events []simEvent, sim *theSimulation) *killCam {
+	kc := &killCam{
+		States:  states,
+		Ships:   make([]int, len(states)),
+		Killers: make([]int, len(states)),
+	}
+
+	dead := false
+	for _, e := range events {
+		if e.Code == eventDEATH {
+			kc.Death, dead = e, true
+		}
+	}
+	if !dead || len(states) == 0 {
+		return nil
+	}
+
+	last := len(states) - 1
+	if sim.UnmarshalBinary(states[last]) != nil {
+		return nil
+	}
+	ship := nearestEntity(sim, flagSPACESHIP, kc.Death.Pos, -1)
+	if ship < 0 {
+		return nil
+	}
+	kc.Player = sim.At(ship).Player
+	mask := uint32(kc.Death.Value)
+	killer := nearestEntity(sim, mask, kc.Death.Pos, -1)
+
+	var next entity // the killer one state later
+	for i := last; i >= 0; i-- {
+		if i < last && sim.UnmarshalBinary(states[i]) != nil {
+			return nil
+		}
+		kc.Ships[i] = sim.Ship(kc.Player)
+		if i < last && killer >= 0 {
+			killer, mask = trackBack(sim, mask, next)
+		}
+		kc.Killers[i] = killer
+		if killer >= 0 {
+			next = *sim.At(killer)
+		}
+	}
+	return kc
+}
+
+// trackBack finds the entity that moved to where next is during one frame.
+// It returns the index of the entity and its mask, or -1 if it did not exist yet.
+func trackBack(sim *theSimulation, mask uint32, next entity) (int, uint32) {
+	// everything that moves was where its last position says it was
+	if i := nearestEntity(sim, mask, next.Pos0, killCamTrackSlop); i >= 0 {
+		return i, mask
+	}
+
+	// except when it went off the screen and came back on the other side
+	for i, e := range sim.Entities {
+		if e.Mask&mask == 0 || e.Mask&flagDELETED != 0 {
+			continue
+		}
+		pos := e.Pos.Add(e.Vel.Mul(runFrameTime))
+		if b := sim.Bounds.Expand(imageSize(e.ImageID).Mul(0.5)); !pos.IntersectsRectangle(b) {
+			pos = pos.Wrap(b)
+		}
+		if pos.Sub(next.Pos).Len() <= killCamTrackSlop {
+			return i, mask
+		}
+	}
+
+	// or it is new debris, which was part of an asteroid
+	if mask == flagDEBRIS {
+		return nearestEntity(sim, flagASTEROID, next.Pos0, debrisSpread+killCamTrackSlop), flagASTEROID
+	}
+	return -1, mask
+}
+
+// nearestEntity returns the index of the entity with any of the flags in mask that is nearest
+// to a point and no further away than maxDist, if that is not negative, or -1 if there is none.
+func nearestEntity(sim *theSimulation, mask uint32, pos mathx.Vec2, maxDist float64) int {
+	best, bestDist := -1, maxDist
+	for i, e := range sim.Entities {
+		if e.Mask&mask == 0 || e.Mask&flagDELETED != 0 {
+			continue
+		}
+		d := e.Pos.Sub(pos).Len()
+		if (maxDist < 0 || d <= maxDist) && (best < 0 || d < bestDist) {
+			best, bestDist = i, d
+		}
+	}
+	return best
+}
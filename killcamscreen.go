@@ -0,0 +1,164 @@
+//go:build !headless
+// +build !headless
+
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+
+	"github.com/askeladdk/pancake"
+	"github.com/askeladdk/pancake/graphics2d"
+	"github.com/askeladdk/pancake/mathx"
+)
+
+const (
+	killCamSpeed  = 0.4  // states shown per frame
+	killCamZoom   = 2    // the camera zooms in on the ship
+	killCamFollow = 0.15 // part of the way to the ship that the camera moves per frame
+	killCamHold   = 60   // frames that the moment of impact stays on the screen
+	killCamMarker = 48   // pixels around the impact at which the marker appears
+)
+
+var killCamColor = color.RGBA{0xff, 0x40, 0x40, 0xff}
+
+// highlightView tints some of the entities of a drawable.
+type highlightView struct {
+	graphics2d.Drawable
+	Indices []int
+	Tint    color.Color
+}
+
+func (v highlightView) TintColorAt(i int) color.Color {
+	for _, j := range v.Indices {
+		if i == j {
+			return mulColor(v.Drawable.TintColorAt(i), v.Tint)
+		}
+	}
+	return v.Drawable.TintColorAt(i)
+}
+
+// killCamScreen shows the last seconds of the game up to the fatal collision in slow motion
+// before the screen that comes after the game. Any key skips it.
+type killCamScreen struct {
+	*gameScreen
+	Cam    *killCam
+	Next   screen
+	Camera freeCamera
+	Marker solidRects
+	Index  int     // state that is shown
+	Time   float64 // states played towards the next one
+	Hold   int     // frames that the impact has been shown
+	Skip   bool
+}
+
+// newKillCamScreen returns the kill cam of the states before a fatal frame with the events of that
+// frame, or just the next screen when there is no death to show.
+func newKillCamScreen(res *resources, sess *session, states [][]byte, events []simEvent, next screen) screen {
+	sim := newSimulation(res, sess.Sim.Seed)
+	cam := newKillCam(states, events, sim)
+	if cam == nil {
+		return next
+	}
+	s := &killCamScreen{
+		gameScreen: newGameScreen(res, &session{Sim: sim}),
+		Cam:        cam,
+		Next:       next,
+		Marker:     solidRects{Image: res.White},
+	}
+	s.Camera.Reset(res.Bounds)
+	s.Camera.Zoom = killCamZoom
+	s.show(0)
+	s.Camera.Center = s.target(1)
+	return s
+}
+
+func (s *killCamScreen) Begin() {}
+
+func (s *killCamScreen) End() {}
+
+func (s *killCamScreen) Key(ev pancake.KeyEvent) error {
+	s.Skip = s.Skip || ev.Flags.Pressed()
+	return nil
+}
+
+// show decodes a state of the kill cam into the simulation.
+func (s *killCamScreen) show(i int) {
+	s.Index = i
+	if err := s.Sim.UnmarshalBinary(s.Cam.States[i]); err != nil {
+		fmt.Println(err) // the states were saved by the same game
+	}
+}
+
+// target returns where the ship is at a point between the last state and the one shown.
+func (s *killCamScreen) target(alpha float64) mathx.Vec2 {
+	if i := s.Cam.Ships[s.Index]; i >= 0 {
+		e := s.Sim.At(i)
+		return e.Pos0.Lerp(e.Pos, alpha)
+	}
+	return s.Cam.Death.Pos
+}
+
+func (s *killCamScreen) Frame(ev pancake.FrameEvent) (screenOp, error) {
+	last := len(s.Cam.States) - 1
+	if s.Skip || s.Hold >= killCamHold {
+		return replace(s.Next), nil
+	}
+
+	if s.Index < last {
+		for s.Time += killCamSpeed; s.Time >= 1 && s.Index < last; s.Time-- {
+			s.show(s.Index + 1)
+		}
+	} else {
+		s.Time = 1
+		s.Hold++
+	}
+
+	s.Camera.Center = s.Camera.Center.Lerp(s.target(s.Time), killCamFollow)
+	s.layoutMarker()
+	s.Text.Clear()
+	fmt.Fprintf(s.Text, "Kill cam\nPress any key to skip")
+	return screenOp{}, nil
+}
+
+// layoutMarker closes four corners in on the point of impact while it is shown.
+func (s *killCamScreen) layoutMarker() {
+	s.Marker.Clear()
+	if s.Hold == 0 {
+		return
+	}
+
+	mid := s.Res.Bounds.Min.Add(s.Res.Bounds.Max).Mul(0.5)
+	pos := s.Cam.Death.Pos.Sub(s.Camera.Center).Mul(s.Camera.Zoom).Add(mid)
+	t := mathx.Clamp(float64(s.Hold)/(killCamHold/4), 0, 1)
+	r := killCamMarker * (2 - t)
+	for _, d := range []mathx.Vec2{{-1, -1}, {1, -1}, {-1, 1}, {1, 1}} {
+		corner := pos.Add(d.Mul(r))
+		inward := corner.Sub(d.Mul(r / 3))
+		s.Marker.Add(mathx.Rectangle{
+			Min: mathx.Vec2{math.Min(corner[0], inward[0]), corner[1] - 1},
+			Max: mathx.Vec2{math.Max(corner[0], inward[0]), corner[1] + 1},
+		}, killCamColor)
+		s.Marker.Add(mathx.Rectangle{
+			Min: mathx.Vec2{corner[0] - 1, math.Min(corner[1], inward[1])},
+			Max: mathx.Vec2{corner[0] + 1, math.Max(corner[1], inward[1])},
+		}, killCamColor)
+	}
+}
+
+func (s *killCamScreen) Draw(ev pancake.DrawEvent) error {
+	s.Shader.Begin()
+	s.Sim.Alpha = s.Time
+	s.Drawer.Draw(s.Background)
+	highlight := highlightView{
+		Drawable: s.Sim,
+		Indices:  []int{s.Cam.Ships[s.Index], s.Cam.Killers[s.Index]},
+		Tint:     killCamColor,
+	}
+	s.Drawer.Draw(cameraView{highlight, s.Camera.View(s.Res.Bounds)})
+	s.Drawer.Draw(&s.Marker)
+	s.Drawer.Draw(s.Text)
+	s.Shader.End()
+	return nil
+}
@@ -0,0 +1,56 @@
+package main
+
+// Lockstep networking: both players run the same simulation and only exchange
+// the controller input of every tick, which is scheduled a few ticks ahead to hide the latency.
+// A tick is simulated once the input of both players is known, so a slow connection stalls the game
+// for both players instead of letting them drift apart.
+
+const (
+	defaultInputDelay = 3
+	maxInputDelay     = 30
+)
+
+type lockstepPeer struct {
+	inputExchange
+	Tick int // next tick to simulate
+}
+
+func newLockstepPeer(link *netLink) *lockstepPeer {
+	return &lockstepPeer{inputExchange: newInputExchange(link)}
+}
+
+func (p *lockstepPeer) Update(sim *theSimulation, local controllerState, step func([]controllerState), observe func(*theSimulation)) bool {
+	if !p.receive() {
+		return true
+	}
+	p.schedule(p.Tick, local)
+
+	states, ok := p.next()
+	if ok {
+		step(states)
+		p.record(p.Tick, sim.StateHash())
+		observe(sim)
+		p.Tick++
+	}
+
+	p.send(p.Tick)
+	return !ok
+}
+
+// next returns the input of both players for the next tick, if it is known.
+func (p *lockstepPeer) next() ([]controllerState, bool) {
+	states := make([]controllerState, len(p.Inputs))
+	for i := range p.Inputs {
+		cs, ok := p.Inputs[i].Get(p.Tick)
+		if !ok {
+			return nil, false
+		}
+		states[i] = cs
+	}
+	return states, true
+}
+
+// Settled is always true because nothing is simulated without knowing all input.
+func (p *lockstepPeer) Settled() bool {
+	return true
+}
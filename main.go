@@ -1,10 +1,13 @@
+//go:build !headless
+// +build !headless
+
 package main
 
 import (
 	"embed"
+	"flag"
 	"fmt"
 	"image"
-	"math/rand"
 	"time"
 
 	"github.com/faiface/beep"
@@ -28,11 +31,6 @@ import (
 )
 
 var (
-	globalGameScreen     *gameScreen
-	globalGameOverScreen *gameOverScreen
-	globalTitleScreen    *titleScreen
-	globalNextScreen     *nextScreen
-
 	//go:embed assets/*
 	assets embed.FS
 )
@@ -71,7 +69,25 @@ func loadMp3(filename string) (*beep.Buffer, error) {
 	}
 }
 
-func run(app pancake.App) error {
+// options are given on the command line.
+type options struct {
+	Host       string        // address to host a network game on
+	Join       string        // address of a network game to join
+	Connect    string        // address of a game server to play on
+	Publish    string        // address to publish the game to spectators on
+	Spectate   string        // address of a game to watch
+	WatchDelay time.Duration // how far a spectator is behind the game
+	Delay      int           // ticks of input delay in a network game
+	Rollback   bool          // host a game with rollback instead of lockstep
+	Versus     bool          // host a versus match instead of a co-op game
+	Bot        string        // difficulty of the autopilot that flies player 2, if any
+	Replay     string        // run log to watch
+	Rewind     bool          // let the player rewind time
+	Fixed      bool          // simulate with fixed-point math
+	Conditions netConditions
+}
+
+func run(app pancake.App, opts options) error {
 	var sheet *graphics.Texture
 	var background *graphics.Texture
 	var gameover *graphics.Texture
@@ -85,8 +101,6 @@ func run(app pancake.App) error {
 
 	speaker.Init(44100, beep.SampleRate(44100).N(time.Second/10))
 
-	rand.Seed(time.Now().Unix())
-
 	if sheet, err = loadTexture("assets/asteroids-arcade.png"); err != nil {
 		return err
 	}
@@ -122,8 +136,9 @@ func run(app pancake.App) error {
 	if mp3, err = loadMp3("assets/Bonkers-for-Arcades.mp3"); err != nil {
 		return err
 	}
-	stream := mp3.Streamer(0, mp3.Len())
-	speaker.Play(beep.Loop(-1, stream))
+	loop := &musicLoop{Buffer: mp3}
+	music := &beep.Ctrl{Streamer: loop}
+	speaker.Play(music)
 
 	gl.ClearColor(0, 0, 0, 0)
 	gl.Clear(gl.COLOR_BUFFER_BIT)
@@ -149,116 +164,211 @@ func run(app pancake.App) error {
 		DPI:     72,
 		Hinting: font.HintingFull,
 	})
-	font16 := text.NewFontFromFace(face16, text.ASCII)
 
 	face12 := truetype.NewFace(ttf, &truetype.Options{
 		Size:    12,
 		DPI:     72,
 		Hinting: font.HintingFull,
 	})
-	font12 := text.NewFontFromFace(face12, text.ASCII)
-
-	text16 := text.NewText(font16)
-	text12 := text.NewText(font12)
-
-	simulation := theSimulation{
-		ImageAtlas: sheet,
-		Images: []graphics.Image{
-			sheet.SubImage(image.Rect(0, 0, 32, 32)),       // spaceship
-			sheet.SubImage(image.Rect(64, 192, 128, 256)),  // asteroid
-			sheet.SubImage(image.Rect(112, 64, 128, 80)),   // bullet
-			sheet.SubImage(image.Rect(128, 192, 160, 224)), // debris
-			sheet.SubImage(image.Rect(160, 192, 192, 224)),
-			sheet.SubImage(image.Rect(128, 224, 160, 256)),
-			sheet.SubImage(image.Rect(160, 224, 192, 256)),
-		},
+
+	bindings := make([]*keyBindings, maxPlayers)
+	controllers := make([]controller, maxPlayers)
+	for i := range bindings {
+		kb, err := loadKeyBindings(i)
+		if err != nil {
+			fmt.Println(err)
+		}
+		bindings[i] = &kb
+		controllers[i] = newKeyboardController(bindings[i])
+	}
+
+	highScores, err := loadHighScores()
+	if err != nil {
+		fmt.Println(err)
+	}
+
+	achievementDefs, err := loadAchievementDefs(assets, "assets/achievements.json")
+	if err != nil {
+		return err
+	}
+
+	achievements, err := loadAchievementStore(achievementDefs)
+	if err != nil {
+		fmt.Println(err)
+	}
+
+	res := &resources{
+		Bindings:     bindings,
+		HighScores:   &highScores,
+		Achievements: achievements,
+		Versus:       defaultVersusRules(),
+		Rewind:       opts.Rewind,
+		Drawer:       newTintDrawer(drawer),
+		Shader:       shader,
+		Font12:       text.NewFontFromFace(face12, text.ASCII),
+		Font16:       text.NewFontFromFace(face16, text.ASCII),
+		White:        newWhiteTexture(),
+		Music:        music,
+		MusicLoop:    loop,
+		Sheet:        sheet,
 		Sounds: []*beep.Buffer{
 			sfxLaser,
 			sfxExplosion,
 			sfxBoing,
 		},
+		Reversed: []*beep.Buffer{
+			reverseBuffer(sfxLaser),
+			reverseBuffer(sfxExplosion),
+			reverseBuffer(sfxBoing),
+		},
 		Bounds: mathx.Rectangle{
 			Min: mathx.Vec2{},
 			Max: mathx.FromPoint(resolution),
 		},
-	}
-
-	globalGameScreen = &gameScreen{
-		Sim:    &simulation,
-		Text:   text16,
-		Drawer: drawer,
-		Shader: shader,
-		Background: staticImage{
-			Image:    background,
-			Position: midscreen,
-		},
-	}
-
-	globalGameOverScreen = &gameOverScreen{
-		Sim:    &simulation,
-		Text:   text16,
-		Drawer: drawer,
-		Shader: shader,
 		Background: staticImage{
 			Image:    background,
 			Position: midscreen,
 		},
-		Title: staticImage{
+		GameOver: staticImage{
 			Image:    gameover,
 			Position: midscreen,
 		},
-	}
-
-	globalTitleScreen = &titleScreen{
-		Drawer: drawer,
-		Shader: shader,
-		Text:   text12,
-		Background: staticImage{
-			Image:    background,
-			Position: midscreen,
-		},
 		Title: staticImage{
 			Image:    title,
 			Position: midscreen,
 		},
-	}
-
-	globalNextScreen = &nextScreen{
-		Sim:    &simulation,
-		Text:   text16,
-		Drawer: drawer,
-		Shader: shader,
-		Background: staticImage{
-			Image:    background,
-			Position: midscreen,
-		},
-		Title: staticImage{
+		NextLevel: staticImage{
 			Image:    nextlevel,
 			Position: midscreen,
 		},
 	}
+	res.Toasts = newToaster(res)
+	for _, r := range imageRects {
+		res.Images = append(res.Images, sheet.SubImage(r))
+	}
+	if opts.Publish != "" {
+		if res.Feed, err = listenFeed(opts.Publish); err != nil {
+			return err
+		}
+		defer res.Feed.Close()
+	}
 
-	sscreenState := screenState{
-		Screen: transitionScreen{
-			To: globalTitleScreen,
-		},
+	stack := screenStack{
+		Res:      res,
+		Effect:   effectFADE,
+		Duration: 0.5,
+	}
+	sim := newSimulation(res, time.Now().Unix())
+	sim.Fixed = opts.Fixed
+	if opts.Bot != "" {
+		settings, err := botDifficulty(opts.Bot)
+		if err != nil {
+			return err
+		}
+		controllers[1] = newBotController(sim, 1, settings, time.Now().UnixNano())
+	}
+	sess := newSession(sim, controllers, res.Achievements)
+
+	switch {
+	case opts.Host != "":
+		setup := netSetup{
+			Seed:     time.Now().UnixNano(),
+			Delay:    opts.Delay,
+			Rollback: opts.Rollback,
+			Mode:     modeCOOP,
+			Fixed:    opts.Fixed,
+		}
+		if opts.Versus {
+			setup.Mode = modeVERSUS
+			setup.Rules = res.Versus
+		}
+		link, err := hostLink(opts.Host, setup)
+		if err != nil {
+			return err
+		}
+		link.Conditions = opts.Conditions
+		stack.Push(newNetConnectScreen(res, sess, link))
+	case opts.Join != "":
+		link, err := joinLink(opts.Join)
+		if err != nil {
+			return err
+		}
+		link.Conditions = opts.Conditions
+		stack.Push(newNetConnectScreen(res, sess, link))
+	case opts.Spectate != "":
+		link, err := dialFeed(opts.Spectate)
+		if err != nil {
+			return err
+		}
+		stack.Push(newSpectatorScreen(res, sess, link, opts.WatchDelay))
+	case opts.Connect != "":
+		link, err := dialServer(opts.Connect)
+		if err != nil {
+			return err
+		}
+		stack.Push(newClientScreen(res, sess, link))
+	case opts.Replay != "":
+		l, err := loadRunLog(opts.Replay)
+		if err != nil {
+			return err
+		}
+		stack.Push(newTitleScreen(res, sess))
+		stack.Push(newPlaybackScreen(res, sess, l))
+	default:
+		stack.Push(newTitleScreen(res, sess))
 	}
 
 	return app.Events(func(event interface{}) error {
 		app.SetTitle(fmt.Sprintf("Asteroids (%d FPS)", app.FrameRate()))
-		return sscreenState.Do(event)
+		return stack.Do(event)
 	})
 }
 
 func main() {
+	var opts options
+	flag.StringVar(&opts.Host, "host", "", "host a network game on an address such as :7777")
+	flag.StringVar(&opts.Join, "join", "", "join the network game at an address such as localhost:7777")
+	flag.StringVar(&opts.Connect, "connect", "", "play on the game server at an address such as tcp://localhost:7777 or udp://localhost:7777")
+	flag.StringVar(&opts.Publish, "publish", "", "publish the game to spectators on an address such as :7778")
+	flag.StringVar(&opts.Spectate, "spectate", "", "watch the game that is published at an address such as localhost:7778")
+	flag.DurationVar(&opts.WatchDelay, "spectate-delay", defaultSpectateDelay, "how far behind the game a spectator is")
+	flag.IntVar(&opts.Delay, "delay", 0, fmt.Sprintf("ticks of input delay in a network game (default %d, or %d with -rollback)", defaultInputDelay, defaultRollbackDelay))
+	flag.BoolVar(&opts.Rollback, "rollback", false, "host a network game that guesses the input of the other player instead of waiting for it")
+	flag.BoolVar(&opts.Versus, "versus", false, "host a versus match instead of a co-op game")
+	flag.StringVar(&opts.Bot, "bot", "", "let an easy, normal or hard autopilot fly player 2")
+	flag.StringVar(&opts.Replay, "replay", "", "watch the run log in a file")
+	flag.BoolVar(&opts.Rewind, "rewind", false, "let the player hold a key to rewind time")
+	flag.BoolVar(&opts.Fixed, "fixed", false, "simulate with fixed-point math, which plays the same on every processor")
+	flag.DurationVar(&opts.Conditions.Latency, "latency", 0, "add latency to the packets that are sent, such as 50ms")
+	flag.DurationVar(&opts.Conditions.Jitter, "jitter", 0, "add up to this much random latency to the packets that are sent")
+	flag.Float64Var(&opts.Conditions.Loss, "loss", 0, "fraction of the packets that are sent to drop, such as 0.05")
+	flag.Parse()
+
+	if opts.Delay == 0 {
+		opts.Delay = defaultInputDelay
+		if opts.Rollback {
+			opts.Delay = defaultRollbackDelay
+		}
+	}
+
+	if opts.Delay < 1 || opts.Delay > maxInputDelay {
+		fmt.Printf("the input delay must be between 1 and %d ticks\n", maxInputDelay)
+		return
+	} else if opts.Conditions.Loss < 0 || opts.Conditions.Loss >= 1 {
+		fmt.Println("the packet loss must be at least 0 and less than 1")
+		return
+	}
+
 	opt := pancake.Options{
 		WindowSize: image.Point{960, 540},
-		Resolution: image.Point{640, 360},
+		Resolution: screenSize,
 		Title:      "Asteroids",
 		FrameRate:  60,
 	}
 
-	if err := pancake.Main(opt, run); err != nil {
+	if err := pancake.Main(opt, func(app pancake.App) error {
+		return run(app, opts)
+	}); err != nil {
 		fmt.Println(err)
 	}
 }
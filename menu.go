@@ -0,0 +1,62 @@
+//go:build !headless
+// +build !headless
+
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/askeladdk/pancake"
+	"github.com/askeladdk/pancake/input"
+)
+
+// menu is a vertical list of items navigated with the arrow keys.
+type menu struct {
+	Items  []string
+	Cursor int
+	Chosen bool
+}
+
+func (m *menu) Reset() {
+	m.Cursor = 0
+	m.Chosen = false
+}
+
+func (m *menu) Key(ev pancake.KeyEvent) {
+	if !ev.Flags.Pressed() {
+		return
+	}
+
+	switch ev.Key {
+	case input.KeyW:
+		fallthrough
+	case input.KeyUp:
+		m.Cursor = (m.Cursor + len(m.Items) - 1) % len(m.Items)
+	case input.KeyS:
+		fallthrough
+	case input.KeyDown:
+		m.Cursor = (m.Cursor + 1) % len(m.Items)
+	case input.KeySpace:
+		fallthrough
+	case input.KeyEnter:
+		m.Chosen = true
+	}
+}
+
+// Choice reports the item that was selected since the last call.
+func (m *menu) Choice() (int, bool) {
+	chosen := m.Chosen
+	m.Chosen = false
+	return m.Cursor, chosen
+}
+
+func (m *menu) Print(w io.Writer) {
+	for i, item := range m.Items {
+		if i == m.Cursor {
+			fmt.Fprintf(w, "> %s\n", item)
+		} else {
+			fmt.Fprintf(w, "  %s\n", item)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+//go:build !headless
+// +build !headless
+
+package main
+
+import "github.com/faiface/beep"
+
+// musicLoop plays a sound over and over, backwards while Reverse is set.
+// It must only be changed while the speaker is locked.
+type musicLoop struct {
+	Buffer  *beep.Buffer
+	Reverse bool
+	pos     int
+}
+
+func (m *musicLoop) Stream(samples [][2]float64) (int, bool) {
+	n := m.Buffer.Len()
+	if n == 0 {
+		return 0, false
+	}
+
+	for done := 0; done < len(samples); {
+		chunk := samples[done:]
+		if m.Reverse {
+			if m.pos == 0 {
+				m.pos = n
+			}
+			chunk = chunk[:minInt(len(chunk), m.pos)]
+			m.pos -= len(chunk)
+			m.read(m.pos, chunk)
+			for i, j := 0, len(chunk)-1; i < j; i, j = i+1, j-1 {
+				chunk[i], chunk[j] = chunk[j], chunk[i]
+			}
+		} else {
+			if m.pos == n {
+				m.pos = 0
+			}
+			chunk = chunk[:minInt(len(chunk), n-m.pos)]
+			m.read(m.pos, chunk)
+			m.pos += len(chunk)
+		}
+		done += len(chunk)
+	}
+	return len(samples), true
+}
+
+func (m *musicLoop) read(from int, samples [][2]float64) {
+	s := m.Buffer.Streamer(from, from+len(samples))
+	for n := 0; n < len(samples); {
+		k, ok := s.Stream(samples[n:])
+		if n += k; !ok {
+			break
+		}
+	}
+}
+
+func (m *musicLoop) Err() error {
+	return nil
+}
+
+// reverseBuffer returns a copy of a sound that plays backwards.
+func reverseBuffer(b *beep.Buffer) *beep.Buffer {
+	samples := make([][2]float64, b.Len())
+	(&musicLoop{Buffer: b}).read(0, samples)
+	for i, j := 0, len(samples)-1; i < j; i, j = i+1, j-1 {
+		samples[i], samples[j] = samples[j], samples[i]
+	}
+
+	reversed := beep.NewBuffer(b.Format())
+	reversed.Append(beep.StreamerFunc(func(out [][2]float64) (int, bool) {
+		if len(samples) == 0 {
+			return 0, false
+		}
+		n := copy(out, samples)
+		samples = samples[n:]
+		return n, true
+	}))
+	return reversed
+}
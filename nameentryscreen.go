@@ -0,0 +1,112 @@
+//go:build !headless
+// +build !headless
+
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/askeladdk/pancake"
+	"github.com/askeladdk/pancake/graphics"
+	"github.com/askeladdk/pancake/input"
+	"github.com/askeladdk/pancake/text"
+)
+
+// nameEntryScreen asks for the player's name when the final score makes it into the high score table.
+type nameEntryScreen struct {
+	*session
+	Res        *resources
+	Text       *text.Text
+	Drawer     *tintDrawer
+	Shader     *graphics.ShaderProgram
+	Background staticImage
+	Name       []byte
+	Done       bool
+}
+
+func newNameEntryScreen(res *resources, sess *session) *nameEntryScreen {
+	return &nameEntryScreen{
+		Res:        res,
+		session:    sess,
+		Text:       res.newMenuText(),
+		Drawer:     res.Drawer,
+		Shader:     res.Shader,
+		Background: res.Background,
+	}
+}
+
+func (s *nameEntryScreen) Begin() {
+	s.Done = false
+	s.Name = s.Name[:0]
+	s.print()
+}
+
+func (s *nameEntryScreen) End() {}
+
+func (s *nameEntryScreen) Key(ev pancake.KeyEvent) error {
+	if !ev.Flags.Pressed() {
+		return nil
+	}
+
+	switch ev.Key {
+	case input.KeyEnter:
+		s.Done = true
+	case input.KeyBackspace:
+		if len(s.Name) > 0 {
+			s.Name = s.Name[:len(s.Name)-1]
+		}
+	case input.KeySpace:
+		s.typeChar(' ')
+	default:
+		if name, ok := keyName(ev.Key); ok && len(name) == 1 {
+			s.typeChar(name[0])
+		}
+	}
+
+	s.print()
+	return nil
+}
+
+func (s *nameEntryScreen) typeChar(c byte) {
+	if len(s.Name) < maxNameLength {
+		s.Name = append(s.Name, c)
+	}
+}
+
+func (s *nameEntryScreen) Frame(ev pancake.FrameEvent) (screenOp, error) {
+	if !s.Done {
+		return screenOp{}, nil
+	}
+
+	rank := s.Res.HighScores.Insert(highScore{
+		Name:  sanitizeName(string(s.Name)),
+		Score: s.Sim.Score,
+		Level: 1 + s.Sim.Level,
+		Date:  time.Now(),
+		Seed:  s.Sim.Seed,
+	})
+
+	if err := saveHighScores(s.Res.HighScores); err != nil {
+		fmt.Println(err)
+	}
+
+	next := newGameOverScreen(s.Res, s.session)
+	next.Rank = rank
+	return replace(next), nil
+}
+
+func (s *nameEntryScreen) Draw(ev pancake.DrawEvent) error {
+	s.Shader.Begin()
+	s.Drawer.Draw(s.Background)
+	s.Drawer.Draw(s.Text)
+	s.Shader.End()
+	return nil
+}
+
+func (s *nameEntryScreen) print() {
+	s.Text.Clear()
+	fmt.Fprintf(s.Text, "New high score: %d\n\n", s.Sim.Score)
+	fmt.Fprintf(s.Text, "Enter your name:\n%s_\n\n", s.Name)
+	fmt.Fprintf(s.Text, "Press Enter when done.")
+}
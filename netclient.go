@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// serverLink connects a client to a game server, over TCP or UDP.
+// The client only sends its controller state and displays the snapshots that it receives.
+type serverLink struct {
+	Conn     net.Conn
+	Stream   bool // a TCP connection, whose packets are preceded by their length
+	Player   int  // the player of the client, -1 until the server has accepted it
+	Tick     int  // tick of the latest snapshot, -1 until the first one arrives
+	Missing  int  // players that the server is waiting for
+	Err      error
+	history  snapshotRing
+	packets  chan []byte
+	lastSeen time.Time
+	lastJoin time.Time
+}
+
+// dialServer connects to a server at an address such as "tcp://localhost:7777" or "udp://localhost:7777".
+// Addresses without a network use TCP.
+func dialServer(addr string) (*serverLink, error) {
+	network := "tcp"
+	if i := strings.Index(addr, "://"); i >= 0 {
+		network, addr = addr[:i], addr[i+3:]
+	}
+	if network != "tcp" && network != "udp" {
+		return nil, fmt.Errorf("unknown network %q", network)
+	}
+
+	conn, err := net.DialTimeout(network, addr, netTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &serverLink{
+		Conn:     conn,
+		Stream:   network == "tcp",
+		Player:   -1,
+		Tick:     -1,
+		packets:  make(chan []byte, 256),
+		lastSeen: time.Now(),
+	}
+	go l.receive()
+	return l, nil
+}
+
+func (l *serverLink) receive() {
+	r := bufio.NewReader(l.Conn)
+	buf := make([]byte, maxUDPPacketSize)
+	for {
+		var data []byte
+		var err error
+		if l.Stream {
+			data, err = readFrame(r)
+		} else if n, rerr := l.Conn.Read(buf); rerr == nil {
+			data = append(data, buf[:n]...)
+		} else {
+			err = rerr
+		}
+
+		if err != nil {
+			close(l.packets)
+			return
+		}
+
+		select {
+		case l.packets <- data:
+		default: // drop it, the next snapshot makes up for it
+		}
+	}
+}
+
+// Receive handles the packets from the server and loads the latest snapshot into a simulation.
+// It returns the events of the ticks whose snapshots arrived.
+func (l *serverLink) Receive(sim *theSimulation) []simEvent {
+	var events []simEvent
+	now := time.Now()
+	for l.Err == nil {
+		select {
+		case data, ok := <-l.packets:
+			if !ok {
+				l.Err = errNetTimeout
+			} else {
+				l.lastSeen = now
+				events = append(events, l.handle(sim, data)...)
+			}
+		default:
+			l.keepAlive(now)
+			return events
+		}
+	}
+	return events
+}
+
+func (l *serverLink) handle(sim *theSimulation, data []byte) []simEvent {
+	kind, r, ok := parsePacket(data)
+	if !ok {
+		return nil
+	}
+
+	switch kind {
+	case packetACCEPT:
+		if player := int(r.u32()); r.err == nil {
+			l.Player = player
+		}
+	case packetBYE:
+		l.Err = errServerLeft
+	case packetSNAPSHOT:
+		var msg snapshotMessage
+		if err := msg.read(r, &l.history); err != nil || msg.Tick <= l.Tick {
+			return nil
+		} else if err := sim.UnmarshalBinary(msg.State); err != nil {
+			l.Err = err
+			return nil
+		}
+
+		l.history.Put(msg.Tick, msg.State)
+		l.Tick = msg.Tick
+		l.Missing = msg.Missing
+		return msg.Events
+	}
+	return nil
+}
+
+// keepAlive asks to join until the server answers and notices when the server has gone quiet.
+func (l *serverLink) keepAlive(now time.Time) {
+	if l.Player < 0 && now.Sub(l.lastJoin) >= netHelloInterval {
+		l.lastJoin = now
+		l.write(makePacket(packetJOIN, nil))
+	}
+	if now.Sub(l.lastSeen) > netTimeout {
+		l.Err = errNetTimeout
+	}
+}
+
+// Send sends the controller state of the client for the next tick,
+// along with the tick of the latest snapshot so that the server knows what to send next.
+func (l *serverLink) Send(cs controllerState) {
+	if l.Player < 0 {
+		return
+	}
+	l.write(makePacket(packetCOMMAND, func(w *saveWriter) {
+		w.i64(int64(l.Tick))
+		writeInput(w, cs)
+	}))
+}
+
+func (l *serverLink) write(data []byte) {
+	var err error
+	if l.Stream {
+		l.Conn.SetWriteDeadline(time.Now().Add(netTimeout))
+		err = writeFrame(l.Conn, data)
+	} else {
+		_, err = l.Conn.Write(data)
+	}
+	if err != nil && l.Err == nil {
+		l.Err = err
+	}
+}
+
+// Close tells the server that the client leaves and closes the connection.
+func (l *serverLink) Close() error {
+	if l.Err == nil {
+		l.write(makePacket(packetBYE, nil))
+	}
+	return l.Conn.Close()
+}
@@ -0,0 +1,85 @@
+//go:build !headless
+// +build !headless
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/askeladdk/pancake"
+	"github.com/askeladdk/pancake/graphics"
+	"github.com/askeladdk/pancake/input"
+	"github.com/askeladdk/pancake/text"
+)
+
+// netConnectScreen waits until the other player has connected and then starts the game.
+type netConnectScreen struct {
+	*session
+	Res        *resources
+	Link       *netLink
+	Text       *text.Text
+	Drawer     *tintDrawer
+	Shader     *graphics.ShaderProgram
+	Background staticImage
+	Cancel     bool
+}
+
+func newNetConnectScreen(res *resources, sess *session, link *netLink) *netConnectScreen {
+	return &netConnectScreen{
+		Res:        res,
+		session:    sess,
+		Link:       link,
+		Text:       res.newMenuText(),
+		Drawer:     res.Drawer,
+		Shader:     res.Shader,
+		Background: res.Background,
+	}
+}
+
+func (s *netConnectScreen) Begin() {
+	s.Cancel = false
+	s.Text.Clear()
+	if s.Link.Host {
+		fmt.Fprintf(s.Text, "Waiting for a player to join\non %s...\n\n", s.Link.Conn.LocalAddr())
+	} else {
+		fmt.Fprintf(s.Text, "Joining the game at\n%s...\n\n", s.Link.Remote)
+	}
+	fmt.Fprintf(s.Text, "Press ESC to cancel.")
+}
+
+func (s *netConnectScreen) End() {}
+
+func (s *netConnectScreen) Key(ev pancake.KeyEvent) error {
+	if ev.Flags.Pressed() && ev.Key == input.KeyEscape {
+		s.Cancel = true
+	}
+	return nil
+}
+
+func (s *netConnectScreen) Frame(ev pancake.FrameEvent) (screenOp, error) {
+	if s.Cancel {
+		s.Link.Close()
+		return replaceAll(newTitleScreen(s.Res, s.session)), nil
+	}
+
+	// input that arrives along with the handshake is dropped, but it is sent again
+	s.Link.Receive()
+
+	if err := s.Link.Err; err != nil {
+		fmt.Println(err)
+		s.Link.Close()
+		return replaceAll(newTitleScreen(s.Res, s.session)), nil
+	} else if s.Link.Connected {
+		s.Link.Setup.NewGame(s.session)
+		return replace(newNetGameScreen(s.Res, s.session, s.Link.Setup.Peer(s.Link))), nil
+	}
+	return screenOp{}, nil
+}
+
+func (s *netConnectScreen) Draw(ev pancake.DrawEvent) error {
+	s.Shader.Begin()
+	s.Drawer.Draw(s.Background)
+	s.Drawer.Draw(s.Text)
+	s.Shader.End()
+	return nil
+}
@@ -0,0 +1,87 @@
+//go:build !headless
+// +build !headless
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/askeladdk/pancake"
+)
+
+// netGameScreen plays a game with another player over the network.
+// The local player uses the keyboard controller of player 1, whichever ship they fly.
+// There is no pausing, and the levels follow each other without stopping in between
+// because both players must stay in step.
+type netGameScreen struct {
+	*gameScreen
+	Peer    netPeer
+	Waiting bool
+	Level   int // level of the last tick that was observed
+}
+
+func newNetGameScreen(res *resources, sess *session, peer netPeer) *netGameScreen {
+	return &netGameScreen{
+		gameScreen: newGameScreen(res, sess),
+		Peer:       peer,
+	}
+}
+
+func (s *netGameScreen) Key(ev pancake.KeyEvent) error {
+	if kr, ok := s.Controllers[0].(keyReceiver); ok {
+		kr.Key(ev)
+	}
+	return nil
+}
+
+// leave ends the network game and moves on to another screen.
+func (s *netGameScreen) leave(op screenOp) (screenOp, error) {
+	s.Peer.Close()
+	return op, nil
+}
+
+// tick simulates a tick for the first time.
+func (s *netGameScreen) tick(states []controllerState) {
+	netAdvance(s.Sim, states)
+}
+
+// observeTick observes a tick once it is final.
+// With rollback that can be a few ticks after it was first simulated.
+func (s *netGameScreen) observeTick(sim *theSimulation) {
+	if sim.Level != s.Level {
+		s.Level = sim.Level
+		s.Floaters.Clear()
+		s.Achievements.BeginLevel()
+	}
+	s.observe(sim, netTickTime)
+	if sim.State == stateNEXTLEVEL {
+		s.Stats.EndLevel(sim)
+	}
+}
+
+func (s *netGameScreen) Frame(ev pancake.FrameEvent) (screenOp, error) {
+	if err := s.Peer.Err(); err != nil {
+		fmt.Println(err)
+		return s.leave(replaceAll(newTitleScreen(s.Res, s.session)))
+	}
+
+	// levels and rounds follow each other without stopping, only the end of the game leaves this screen,
+	// and a game that ended on a guess of the input of the other player may not be over yet
+	if netGameOver(s.Sim) && s.Peer.Settled() {
+		op, _ := s.checkState()
+		return s.leave(op)
+	}
+
+	cs := s.Controllers[0].Poll()
+	if cs.Pressed&buttonPause != 0 {
+		return s.leave(replaceAll(newTitleScreen(s.Res, s.session)))
+	}
+
+	s.Waiting = s.Peer.Update(s.Sim, cs, s.tick, s.observeTick)
+
+	s.printStatus()
+	if s.Waiting {
+		fmt.Fprintf(s.Text, "\nWaiting for the other player...")
+	}
+	return screenOp{}, nil
+}
@@ -0,0 +1,606 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"time"
+)
+
+// Networked games exchange small UDP packets that start with a magic number,
+// the protocol version and the kind of packet.
+const (
+	netMagic   = "ASTN"
+	netVersion = 5
+)
+
+const (
+	packetHELLO    = iota + 1 // a player asks to join
+	packetWELCOME             // the host accepts and describes the game
+	packetBYE                 // the sender leaves the game
+	packetINPUT               // controller input, see lockstepPeer and rollbackPeer
+	packetJOIN                // a client asks a server for a ship
+	packetACCEPT              // the server tells a client which player it is
+	packetCOMMAND             // the controller state of a client, see gameServer
+	packetSNAPSHOT            // the game state that a server sends to its clients
+)
+
+const (
+	netTickRate      = 60 // networked games advance in fixed steps
+	netTickTime      = 1.0 / netTickRate
+	netTimeout       = 5 * time.Second
+	netHelloInterval = 250 * time.Millisecond
+	netMaxPacketSize = 1400
+)
+
+var (
+	errNetTimeout = errors.New("connection lost")
+	errPeerLeft   = errors.New("the other player left")
+	errServerLeft = errors.New("the server ended the game")
+	errBadSetup   = errors.New("the host set up a game that cannot be played")
+)
+
+// netSetup is what the host tells a joining player about the game.
+type netSetup struct {
+	Seed     int64
+	Delay    int  // ticks of input delay
+	Rollback bool // guess the input of the other player instead of waiting for it
+	Mode     gameMode
+	Rules    versusRules
+	Fixed    bool // simulate with fixed-point math
+}
+
+func (ns *netSetup) write(w *saveWriter) {
+	w.i64(ns.Seed)
+	w.u32(uint32(ns.Delay))
+	w.bool(ns.Rollback)
+	w.u32(uint32(ns.Mode))
+	w.u32(uint32(ns.Rules.RoundsToWin))
+	w.u32(uint32(ns.Rules.Lives))
+	w.u32(uint32(ns.Rules.Asteroids))
+	w.bool(ns.Rules.FriendlyFire)
+	w.bool(ns.Fixed)
+}
+
+// read reads the setup of the host and fails with errBadSetup if it cannot be played,
+// the same way as a save file that cannot be restored.
+func (ns *netSetup) read(r *saveReader) {
+	ns.Seed = r.i64()
+	ns.Delay = int(r.u32())
+	ns.Rollback = r.bool()
+	ns.Mode = gameMode(r.u32())
+	ns.Rules.RoundsToWin = int(r.u32())
+	ns.Rules.Lives = int(r.u32())
+	ns.Rules.Asteroids = int(r.u32())
+	ns.Rules.FriendlyFire = r.bool()
+	ns.Fixed = r.bool()
+
+	if r.err != nil {
+		return
+	} else if ns.Delay < 1 || ns.Delay > maxInputDelay {
+		r.err = errBadSetup
+	} else if ns.Mode != modeCOOP && ns.Mode != modeVERSUS {
+		r.err = errBadSetup
+	} else if ns.Mode == modeVERSUS && !ns.Rules.Valid() {
+		r.err = errBadSetup
+	}
+}
+
+// Peer returns what keeps the game in step with the other player.
+func (ns *netSetup) Peer(link *netLink) netPeer {
+	if ns.Rollback {
+		return newRollbackPeer(link)
+	}
+	return newLockstepPeer(link)
+}
+
+// NewGame starts the game that the host set up.
+func (ns *netSetup) NewGame(sess *session) {
+	sess.Sim.Fixed = ns.Fixed
+	if ns.Mode == modeVERSUS {
+		sess.NewMatch(ns.Seed, maxPlayers, ns.Rules)
+	} else {
+		sess.NewGame(ns.Seed, maxPlayers)
+	}
+	// rollback simulates frames more than once, so network games are not logged
+	sess.RunLog = nil
+}
+
+// netButtons are the buttons that are sent over the network.
+// The others control the local game and make no sense for the other player.
+const netButtons = buttonFire | buttonHyperspace
+
+func writeInput(w *saveWriter, cs controllerState) {
+	w.f64(cs.Turn)
+	w.f64(cs.Thrust)
+	w.u32(uint32(cs.Pressed & netButtons))
+}
+
+func readInput(r *saveReader) controllerState {
+	return controllerState{
+		Turn:    r.f64(),
+		Thrust:  r.f64(),
+		Pressed: controllerButtons(r.u32()) & netButtons,
+	}
+}
+
+// inputBuffer holds the inputs of one player without gaps, indexed by tick.
+type inputBuffer struct {
+	Base   int // tick of the first state
+	States []controllerState
+}
+
+// Len returns the tick after the last known input.
+func (b *inputBuffer) Len() int {
+	return b.Base + len(b.States)
+}
+
+func (b *inputBuffer) Get(tick int) (controllerState, bool) {
+	if tick < b.Base || tick >= b.Len() {
+		return controllerState{}, false
+	}
+	return b.States[tick-b.Base], true
+}
+
+// Put stores the input of the next tick. Inputs that are already known or leave a gap are ignored.
+func (b *inputBuffer) Put(tick int, cs controllerState) {
+	if tick == b.Len() {
+		b.States = append(b.States, cs)
+	}
+}
+
+// Trim forgets the inputs before a tick.
+func (b *inputBuffer) Trim(tick int) {
+	if n := tick - b.Base; n > 0 && n <= len(b.States) {
+		b.States = append(b.States[:0], b.States[n:]...)
+		b.Base = tick
+	}
+}
+
+// netPeer keeps a simulation in step with that of the other player.
+type netPeer interface {
+	// Update exchanges input with the other player and advances the simulation.
+	// It calls step for every tick that is simulated for the first time,
+	// and observe with the game as it was after a tick once the tick is final.
+	// It reports whether it had to wait for the other player instead.
+	Update(sim *theSimulation, local controllerState, step func([]controllerState), observe func(*theSimulation)) (waiting bool)
+
+	// Settled reports whether the simulation is in its final state, which cannot be rolled back.
+	Settled() bool
+
+	Err() error
+	Close() error
+}
+
+// netAdvance simulates one tick of a network game.
+// Network games move on to the next level or round right away, because both players must stay in step.
+func netAdvance(sim *theSimulation, states []controllerState) {
+	if sim.State == stateNEXTLEVEL || (sim.State == stateROUNDOVER && sim.MatchWinner() < 0) {
+		sim.Level++
+		sim.Reset()
+	}
+
+	for i, cs := range states {
+		cs.Apply(sim, i)
+	}
+	sim.Frame(netTickTime)
+}
+
+// netGameOver reports whether a network game is over.
+// The end of a versus match is the end of the last round.
+func netGameOver(sim *theSimulation) bool {
+	return sim.State == stateGAMEOVER || (sim.State == stateROUNDOVER && sim.MatchWinner() >= 0)
+}
+
+const (
+	maxInputsInPacket = 60  // how many unacknowledged inputs are resent in every packet
+	hashHistory       = 256 // ticks of hashes that are kept to compare with the other player
+)
+
+type tickHash struct {
+	Tick int
+	Hash uint64
+}
+
+// inputExchange sends the local input to the other player until it is acknowledged
+// and receives theirs. Every packet also carries the hash of a recent tick,
+// which is compared with the same tick here to find out when the games have drifted apart.
+type inputExchange struct {
+	Link     *netLink
+	Inputs   [maxPlayers]inputBuffer
+	peerAck  int // ticks of local input that the other player has received
+	hashes   [hashHistory]tickHash
+	last     tickHash          // the latest hash recorded here
+	peerHash tickHash          // the latest hash received from the other player
+	pressed  controllerButtons // buttons pressed since the local input was last scheduled
+	started  bool
+}
+
+func newInputExchange(link *netLink) inputExchange {
+	return inputExchange{
+		Link:     link,
+		last:     tickHash{Tick: -1},
+		peerHash: tickHash{Tick: -1},
+	}
+}
+
+func (x *inputExchange) Err() error {
+	return x.Link.Err
+}
+
+func (x *inputExchange) Close() error {
+	return x.Link.Close()
+}
+
+// Me returns the index of the local player.
+func (x *inputExchange) Me() int {
+	return x.Link.Player()
+}
+
+// Other returns the index of the other player.
+func (x *inputExchange) Other() int {
+	return 1 - x.Link.Player()
+}
+
+// receive handles the packets of the other player.
+// It reports whether the game can go on, which is once the game setup is known.
+func (x *inputExchange) receive() bool {
+	messages := x.Link.Receive()
+	if !x.Link.Connected || x.Link.Err != nil {
+		return false
+	} else if !x.started {
+		// the input delay is filled with idle input
+		x.started = true
+		for i := range x.Inputs {
+			for t := 0; t < x.Link.Setup.Delay; t++ {
+				x.Inputs[i].Put(t, controllerState{})
+			}
+		}
+	}
+
+	for _, m := range messages {
+		if m.Kind == packetINPUT {
+			x.readInputs(m.Body)
+		}
+	}
+	return true
+}
+
+// schedule stores the local input for the tick that is the input delay ahead of a tick,
+// unless the input of that tick is already known. Buttons that are pressed while the game waits
+// are kept until the input of the next tick is stored, so that no shot gets lost.
+func (x *inputExchange) schedule(tick int, local controllerState) {
+	x.pressed |= local.Pressed & netButtons
+	if buf := &x.Inputs[x.Me()]; buf.Len() <= tick+x.Link.Setup.Delay {
+		local.Pressed = x.pressed
+		x.pressed = 0
+		buf.Put(buf.Len(), local)
+	}
+}
+
+// send trims the inputs before a tick that are no longer needed
+// and sends the local input that the other player has not acknowledged yet,
+// so that lost packets are made up for by the next one.
+func (x *inputExchange) send(tick int) {
+	x.Inputs[x.Me()].Trim(minInt(x.peerAck, tick))
+	x.Inputs[x.Other()].Trim(tick)
+	x.Link.Send(packetINPUT, x.writeInputs)
+}
+
+func (x *inputExchange) writeInputs(w *saveWriter) {
+	buf := &x.Inputs[x.Me()]
+	first := x.peerAck
+	if first < buf.Base {
+		first = buf.Base
+	}
+	count := minInt(buf.Len()-first, maxInputsInPacket)
+
+	w.u32(uint32(x.Me()))
+	w.u32(uint32(x.Inputs[x.Other()].Len()))
+	w.u32(uint32(first))
+	w.u32(uint32(count))
+	for t := first; t < first+count; t++ {
+		cs, _ := buf.Get(t)
+		writeInput(w, cs)
+	}
+	w.i64(int64(x.last.Tick))
+	w.u64(x.last.Hash)
+}
+
+func (x *inputExchange) readInputs(r *saveReader) {
+	if player := int(r.u32()); player != x.Other() {
+		return
+	}
+	ack := int(r.u32())
+	first := int(r.u32())
+	count := int(r.u32())
+	if r.err != nil || count > maxInputsInPacket {
+		return
+	}
+
+	states := make([]controllerState, count)
+	for i := range states {
+		states[i] = readInput(r)
+	}
+	peerHash := tickHash{Tick: int(r.i64()), Hash: r.u64()}
+	if r.err != nil {
+		return
+	}
+
+	if ack > x.peerAck {
+		x.peerAck = ack
+	}
+	for i, cs := range states {
+		x.Inputs[x.Other()].Put(first+i, cs)
+	}
+	if peerHash.Tick > x.peerHash.Tick {
+		x.peerHash = peerHash
+		x.checkHash()
+	}
+}
+
+// record remembers the hash of the game state after a tick that is final.
+func (x *inputExchange) record(tick int, hash uint64) {
+	x.last = tickHash{tick, hash}
+	x.hashes[tick%hashHistory] = x.last
+	x.checkHash()
+}
+
+func (x *inputExchange) checkHash() {
+	ph := x.peerHash
+	if ph.Tick < 0 || ph.Tick > x.last.Tick || ph.Tick <= x.last.Tick-hashHistory {
+		return
+	} else if h := x.hashes[ph.Tick%hashHistory]; h.Tick == ph.Tick && h.Hash != ph.Hash {
+		x.Link.Err = fmt.Errorf("desync at tick %d", ph.Tick)
+	}
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// makePacket encodes a packet. The body is written by a function.
+func makePacket(kind uint32, body func(w *saveWriter)) []byte {
+	var buf bytes.Buffer
+	w := saveWriter{w: &buf}
+	w.bytes([]byte(netMagic))
+	w.u32(netVersion)
+	w.u32(kind)
+	if body != nil {
+		body(&w)
+	}
+	return buf.Bytes()
+}
+
+// parsePacket checks the header of a packet and returns its kind and a reader of the body.
+func parsePacket(data []byte) (uint32, *saveReader, bool) {
+	r := &saveReader{r: bytes.NewReader(data)}
+	if magic := r.bytes(len(netMagic)); r.err != nil || string(magic) != netMagic {
+		return 0, nil, false
+	} else if version := r.u32(); r.err != nil || version != netVersion {
+		return 0, nil, false
+	}
+	kind := r.u32()
+	return kind, r, r.err == nil
+}
+
+type netMessage struct {
+	Kind uint32
+	Body *saveReader
+}
+
+type netPacket struct {
+	From *net.UDPAddr
+	Data []byte
+}
+
+// netConditions make the connection worse than it is,
+// to try out how the game copes with a bad connection on a single machine.
+type netConditions struct {
+	Latency time.Duration // added to every packet
+	Jitter  time.Duration // at most this much random latency is added on top
+	Loss    float64       // fraction of the packets that are dropped
+}
+
+// netLink is a UDP connection between two players.
+// The host waits for the other player to say hello and answers with the game setup.
+// Packets are read on a separate goroutine and handled on the game loop by Receive.
+type netLink struct {
+	Conn       *net.UDPConn
+	Remote     *net.UDPAddr // the other player, nil until the host hears from them
+	Host       bool
+	Setup      netSetup
+	Connected  bool
+	Err        error
+	Conditions netConditions // applied to the packets that are sent
+	packets    chan netPacket
+	lastSeen   time.Time
+	lastHello  time.Time
+}
+
+// hostLink waits for a player on a local address such as ":7777".
+func hostLink(addr string, setup netSetup) (*netLink, error) {
+	laddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := net.ListenUDP("udp", laddr)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &netLink{
+		Conn:    conn,
+		Host:    true,
+		Setup:   setup,
+		packets: make(chan netPacket, 256),
+	}
+	go l.receive()
+	return l, nil
+}
+
+// joinLink joins the game of a host at an address such as "192.168.1.10:7777".
+func joinLink(addr string) (*netLink, error) {
+	raddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := net.ListenUDP("udp", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &netLink{
+		Conn:    conn,
+		Remote:  raddr,
+		packets: make(chan netPacket, 256),
+	}
+	go l.receive()
+	return l, nil
+}
+
+// Player returns the index of the local player.
+func (l *netLink) Player() int {
+	if l.Host {
+		return 0
+	}
+	return 1
+}
+
+func (l *netLink) receive() {
+	buf := make([]byte, netMaxPacketSize)
+	for {
+		n, from, err := l.Conn.ReadFromUDP(buf)
+		if err != nil {
+			close(l.packets)
+			return
+		}
+
+		select {
+		case l.packets <- netPacket{from, append([]byte(nil), buf[:n]...)}:
+		default: // drop it, the game loop is not keeping up
+		}
+	}
+}
+
+// Receive takes care of the handshake and returns the game packets that arrived since the last call.
+func (l *netLink) Receive() []netMessage {
+	var messages []netMessage
+	now := time.Now()
+
+	for l.Err == nil {
+		var p netPacket
+		var ok bool
+		select {
+		case p, ok = <-l.packets:
+		default:
+			return l.keepAlive(now, messages)
+		}
+
+		if !ok {
+			l.Err = errNetTimeout
+			break
+		}
+
+		kind, r, ok := parsePacket(p.Data)
+		if !ok {
+			continue
+		}
+
+		if kind == packetHELLO && l.Host && l.Remote == nil {
+			l.Remote = p.From
+			l.Connected = true
+		}
+
+		if l.Remote == nil || p.From.String() != l.Remote.String() {
+			continue
+		}
+		l.lastSeen = now
+
+		switch kind {
+		case packetHELLO:
+			if l.Host {
+				l.Send(packetWELCOME, l.Setup.write)
+			}
+		case packetWELCOME:
+			if !l.Host && !l.Connected {
+				l.Setup.read(r)
+				l.Connected = r.err == nil
+				if r.err == errBadSetup {
+					l.Err = r.err
+				}
+			}
+		case packetBYE:
+			l.Err = errPeerLeft
+		default:
+			if l.Connected {
+				messages = append(messages, netMessage{kind, r})
+			}
+		}
+	}
+	return messages
+}
+
+// keepAlive says hello until the host answers and notices when the other player has gone quiet.
+func (l *netLink) keepAlive(now time.Time, messages []netMessage) []netMessage {
+	if !l.Host && !l.Connected && now.Sub(l.lastHello) >= netHelloInterval {
+		l.lastHello = now
+		l.Send(packetHELLO, nil)
+	} else if l.Connected && now.Sub(l.lastSeen) > netTimeout {
+		l.Err = errNetTimeout
+	}
+	return messages
+}
+
+// Send sends a packet to the other player. The body is written by a function.
+func (l *netLink) Send(kind uint32, body func(w *saveWriter)) {
+	if l.Remote == nil {
+		return
+	}
+
+	data := makePacket(kind, body)
+	c := l.Conditions
+	if c.Loss > 0 && rand.Float64() < c.Loss {
+		return
+	}
+
+	delay := c.Latency
+	if c.Jitter > 0 {
+		delay += time.Duration(rand.Int63n(int64(c.Jitter)))
+	}
+	if delay > 0 {
+		time.AfterFunc(delay, func() { l.write(data) })
+	} else {
+		l.write(data)
+	}
+}
+
+func (l *netLink) write(data []byte) {
+	// packets that were held back by the simulated conditions may be sent after closing
+	if _, err := l.Conn.WriteToUDP(data, l.Remote); err != nil && !errors.Is(err, net.ErrClosed) {
+		fmt.Println(err)
+	}
+}
+
+// Close says goodbye to the other player and closes the connection.
+func (l *netLink) Close() error {
+	// goodbye is not held up by the simulated conditions because the connection closes right away
+	if l.Connected && l.Err == nil {
+		l.write(makePacket(packetBYE, nil))
+	}
+	return l.Conn.Close()
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// connectLinks connects a host and a player that joins it on this machine.
+func connectLinks(t *testing.T, setup netSetup, cond netConditions) (host, join *netLink) {
+	host, err := hostLink("127.0.0.1:0", setup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	join, err = joinLink(host.Conn.LocalAddr().String())
+	if err != nil {
+		host.Close()
+		t.Fatal(err)
+	}
+	host.Conditions = cond
+	join.Conditions = cond
+
+	for i := 0; i < 2000 && !(host.Connected && join.Connected); i++ {
+		host.Receive()
+		join.Receive()
+		time.Sleep(time.Millisecond)
+	}
+	if !host.Connected || !join.Connected {
+		host.Close()
+		join.Close()
+		t.Fatal("the player could not join the host")
+	}
+	return host, join
+}
+
+// netTrace is what a player observed of a network game: the hash of the game after every final tick
+// and the events of those ticks.
+type netTrace struct {
+	Hashes []uint64
+	Events []simEvent
+}
+
+func (tr *netTrace) observe(sim *theSimulation) {
+	tr.Hashes = append(tr.Hashes, sim.StateHash())
+	tr.Events = append(tr.Events, sim.Events...)
+}
+
+// playNet plays a network game between two instances on this machine until both observed a number of ticks.
+// The players press their buttons in a pattern that the other one cannot guess.
+func playNet(t *testing.T, setup netSetup, cond netConditions, ticks int) [2]netTrace {
+	host, join := connectLinks(t, setup, cond)
+	defer host.Close()
+	defer join.Close()
+
+	var traces [2]netTrace
+	peers := [2]netPeer{setup.Peer(host), setup.Peer(join)}
+	var sims [2]*theSimulation
+	for i := range sims {
+		sims[i] = newBareSimulation(setup.Seed)
+		sims[i].NewGame(setup.Seed, maxPlayers)
+		sims[i].Reset()
+	}
+
+	for frame := 0; len(traces[0].Hashes) < ticks || len(traces[1].Hashes) < ticks; frame++ {
+		if frame > 50*ticks {
+			t.Fatalf("the game stalled after %d and %d ticks", len(traces[0].Hashes), len(traces[1].Hashes))
+		}
+		for i, p := range peers {
+			cs := controllerState{Turn: float64(1 - 2*i), Thrust: float64(frame / 40 % 2)}
+			if (frame/3+5*i)%7 == 0 {
+				cs.Pressed = buttonFire
+			}
+			sim, tr := sims[i], &traces[i]
+			p.Update(sim, cs, func(states []controllerState) { netAdvance(sim, states) }, tr.observe)
+			if err := p.Err(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return traces
+}
+
+// sameTrace checks that two players observed the same game for as long as both observed it.
+func sameTrace(t *testing.T, traces [2]netTrace) {
+	a, b := traces[0], traces[1]
+	n := minInt(len(a.Hashes), len(b.Hashes))
+	for i := 0; i < n; i++ {
+		if a.Hashes[i] != b.Hashes[i] {
+			t.Fatalf("tick %d: the players observed different games", i)
+		}
+	}
+
+	n = minInt(len(a.Events), len(b.Events))
+	for i := 0; i < n; i++ {
+		if a.Events[i] != b.Events[i] {
+			t.Fatalf("the players observed different events: %+v and %+v", a.Events[i], b.Events[i])
+		}
+	}
+}
+
+func TestLockstepOnLocalhost(t *testing.T) {
+	setup := netSetup{Seed: 3, Delay: defaultInputDelay}
+	cond := netConditions{Latency: 20 * time.Millisecond, Jitter: 10 * time.Millisecond, Loss: 0.05}
+	sameTrace(t, playNet(t, setup, cond, 300))
+}
+
+func TestPressesAreKeptWhileWaiting(t *testing.T) {
+	x := newInputExchange(&netLink{Host: true, Setup: netSetup{Delay: 1}})
+	x.schedule(0, controllerState{})
+	x.schedule(0, controllerState{})
+
+	// the game waits for the other player, so tick 0 is not simulated yet
+	x.schedule(0, controllerState{Pressed: buttonFire})
+	x.schedule(0, controllerState{Pressed: buttonHyperspace | buttonPause})
+	x.schedule(1, controllerState{Turn: 1})
+
+	cs, ok := x.Inputs[x.Me()].Get(2)
+	if !ok || cs.Pressed != buttonFire|buttonHyperspace || cs.Turn != 1 {
+		t.Fatalf("scheduled %+v", cs)
+	}
+}
+
+func TestRollbackObservesFinalTicks(t *testing.T) {
+	setup := netSetup{Seed: 7, Delay: defaultRollbackDelay, Rollback: true}
+	cond := netConditions{Latency: 30 * time.Millisecond, Jitter: 20 * time.Millisecond, Loss: 0.05}
+	sameTrace(t, playNet(t, setup, cond, 300))
+}
+
+func TestRollbackPlaysOnlyNewSounds(t *testing.T) {
+	before := []simEvent{{Code: eventFIRE}, {Code: eventBOUNCE}}
+	after := []simEvent{{Code: eventFIRE}, {Code: eventDESTROYED}, {Code: eventSCORE}, {Code: eventFIRE}}
+	sounds := newSounds(before, after)
+	if len(sounds) != 2 || sounds[0] != eventSounds[eventDESTROYED] || sounds[1] != eventSounds[eventFIRE] {
+		t.Fatalf("played %v", sounds)
+	}
+}
+
+func TestJoinRejectsBadSetup(t *testing.T) {
+	good := netSetup{Seed: 1, Delay: defaultInputDelay, Mode: modeVERSUS, Rules: defaultVersusRules()}
+	bad := []netSetup{good, good, good, good, good}
+	bad[0].Delay = 0
+	bad[1].Delay = maxInputDelay + 1
+	bad[2].Delay = 1 << 31
+	bad[3].Mode = modeVERSUS + 1
+	bad[4].Rules.RoundsToWin = 0
+
+	for i, setup := range append([]netSetup{good}, bad...) {
+		data := makePacket(packetWELCOME, setup.write)
+		_, r, _ := parsePacket(data)
+		var got netSetup
+		got.read(r)
+		if i == 0 && (r.err != nil || got != good) {
+			t.Fatalf("read %+v, %v", got, r.err)
+		} else if i > 0 && r.err != errBadSetup {
+			t.Fatalf("accepted %+v", got)
+		}
+	}
+}
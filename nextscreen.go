@@ -1,3 +1,6 @@
+//go:build !headless
+// +build !headless
+
 package main
 
 import (
@@ -5,28 +8,41 @@ import (
 
 	"github.com/askeladdk/pancake"
 	"github.com/askeladdk/pancake/graphics"
-	gl "github.com/askeladdk/pancake/graphics/opengl"
-	"github.com/askeladdk/pancake/graphics2d"
 	"github.com/askeladdk/pancake/input"
 	"github.com/askeladdk/pancake/text"
 )
 
 type nextScreen struct {
-	Sim        *theSimulation
+	*session
+	Res        *resources
 	Text       *text.Text
 	Background staticImage
 	Title      staticImage
-	Drawer     *graphics2d.Drawer
+	Drawer     *tintDrawer
 	Shader     *graphics.ShaderProgram
 	Start      bool
 }
 
+func newNextScreen(res *resources, sess *session) *nextScreen {
+	return &nextScreen{
+		Res:        res,
+		session:    sess,
+		Text:       text.NewText(res.Font16),
+		Background: res.Background,
+		Title:      res.NextLevel,
+		Drawer:     res.Drawer,
+		Shader:     res.Shader,
+	}
+}
+
 func (s *nextScreen) Begin() {
 	s.Start = false
 	s.Sim.Level++
 
 	s.Text.Clear()
-	fmt.Fprintf(s.Text, "Level: %d\nScore: %d\nPress Enter to continue.", 1+s.Sim.Level, s.Sim.Score)
+	fmt.Fprintf(s.Text, "Level: %d\nScore: %d\n", 1+s.Sim.Level, s.Sim.Score)
+	s.Stats.PrintLevel(s.Text)
+	fmt.Fprintf(s.Text, "Press Enter to continue.")
 }
 
 func (s *nextScreen) End() {}
@@ -38,15 +54,14 @@ func (s *nextScreen) Key(ev pancake.KeyEvent) error {
 	return nil
 }
 
-func (s *nextScreen) Frame(ev pancake.FrameEvent) (screen, error) {
+func (s *nextScreen) Frame(ev pancake.FrameEvent) (screenOp, error) {
 	if s.Start {
-		return globalGameScreen, nil
+		return replace(newGameScreen(s.Res, s.session)), nil
 	}
-	return nil, nil
+	return screenOp{}, nil
 }
 
 func (s *nextScreen) Draw(ev pancake.DrawEvent) error {
-	gl.Clear(gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT)
 	s.Shader.Begin()
 	s.Drawer.Draw(s.Background)
 	s.Drawer.Draw(s.Title)
@@ -0,0 +1,114 @@
+//go:build !headless
+// +build !headless
+
+package main
+
+import (
+	"fmt"
+	"image/color"
+
+	"github.com/askeladdk/pancake"
+	"github.com/askeladdk/pancake/graphics"
+	"github.com/askeladdk/pancake/text"
+)
+
+// pauseFadeTime is how long it takes for the pause menu to appear and disappear.
+const pauseFadeTime = 0.15
+
+const (
+	pauseResume = iota
+	pauseRestart
+	pauseSettings
+	pauseQuit
+)
+
+type pauseScreen struct {
+	Res    *resources
+	Game   *gameScreen
+	Text   *text.Text
+	Drawer *tintDrawer
+	Shader *graphics.ShaderProgram
+	Dim    solidRect
+	Menu   menu
+	Resume bool
+}
+
+func newPauseScreen(res *resources, game *gameScreen) *pauseScreen {
+	return &pauseScreen{
+		Res:    res,
+		Game:   game,
+		Text:   res.newMenuText(),
+		Drawer: res.Drawer,
+		Shader: res.Shader,
+		Dim: solidRect{
+			Image: res.White,
+			Rect:  res.Bounds,
+			Color: color.RGBA{0, 0, 0, 0xa0},
+		},
+		Menu: menu{
+			Items: []string{
+				pauseResume:   "Resume",
+				pauseRestart:  "Restart level",
+				pauseSettings: "Settings",
+				pauseQuit:     "Quit to title",
+			},
+		},
+	}
+}
+
+func (s *pauseScreen) Begin() {
+	s.Resume = false
+	s.Menu.Reset()
+	s.print()
+}
+
+func (s *pauseScreen) End() {}
+
+func (s *pauseScreen) Overlay() bool { return true }
+
+func (s *pauseScreen) Key(ev pancake.KeyEvent) error {
+	if s.Res.isPauseKey(ev.Key) {
+		s.Resume = s.Resume || ev.Flags.Pressed()
+		return nil
+	}
+	s.Menu.Key(ev)
+	s.print()
+	return nil
+}
+
+func (s *pauseScreen) Frame(ev pancake.FrameEvent) (screenOp, error) {
+	choice, chosen := s.Menu.Choice()
+	if s.Resume {
+		choice, chosen = pauseResume, true
+	}
+
+	if !chosen {
+		return screenOp{}, nil
+	}
+
+	switch choice {
+	case pauseResume:
+		return pop().With(effectDISSOLVE, pauseFadeTime), nil
+	case pauseRestart:
+		s.Game.Restart()
+		return pop(), nil
+	case pauseSettings:
+		return push(newSettingsScreen(s.Res, s.Game.Sim)), nil
+	default:
+		return replaceAll(newTitleScreen(s.Res, s.Game.session)), nil
+	}
+}
+
+func (s *pauseScreen) Draw(ev pancake.DrawEvent) error {
+	s.Shader.Begin()
+	s.Drawer.Draw(s.Dim)
+	s.Drawer.Draw(s.Text)
+	s.Shader.End()
+	return nil
+}
+
+func (s *pauseScreen) print() {
+	s.Text.Clear()
+	fmt.Fprintf(s.Text, "Paused\n\n")
+	s.Menu.Print(s.Text)
+}
@@ -0,0 +1,113 @@
+package main
+
+// Playback plays a run log back and forth. Playing it once up front takes a keyframe of the game
+// every few seconds and marks the deaths and level clears on the timeline. Seeking goes back
+// to the keyframe before the frame to show and simulates the frames in between.
+
+const playbackKeyframeInterval = 300 // frames between keyframes
+
+const (
+	markDEATH = iota // a ship was destroyed
+	markLEVEL        // a level was cleared
+)
+
+// timelineMark is something that happened during the run.
+type timelineMark struct {
+	Frame int
+	Kind  int
+}
+
+// playbackKeyframe is the game and the position in the log at a frame.
+type playbackKeyframe struct {
+	Frame      int
+	State      []byte
+	actions    int
+	restarts   int
+	startScore int
+	start      []player
+}
+
+type runPlayback struct {
+	*runReplay
+	Keyframes []playbackKeyframe
+	Marks     []timelineMark
+	Length    int   // frames that can be played
+	Err       error // why the log cannot be played to the end, if it cannot
+}
+
+func newRunPlayback(l *runLog, sim *theSimulation) *runPlayback {
+	pb := &runPlayback{runReplay: newRunReplay(l, sim)}
+	silent := sim.Silent
+	sim.Silent = true
+	for {
+		if pb.Frame%playbackKeyframeInterval == 0 {
+			pb.keyframe()
+		}
+		if pb.Done() {
+			break
+		} else if err := pb.Step(); err != nil {
+			pb.Err = err
+			break
+		}
+		pb.mark()
+	}
+	pb.Length = pb.Frame
+	pb.restore(pb.Keyframes[0])
+	sim.Silent = silent
+	return pb
+}
+
+func (pb *runPlayback) keyframe() {
+	state, _ := pb.Sim.MarshalBinary()
+	pb.Keyframes = append(pb.Keyframes, playbackKeyframe{
+		Frame:      pb.Frame,
+		State:      state,
+		actions:    pb.actions,
+		restarts:   pb.restarts,
+		startScore: pb.startScore,
+		start:      append([]player(nil), pb.start...),
+	})
+}
+
+func (pb *runPlayback) mark() {
+	for _, e := range pb.Sim.Events {
+		if e.Code == eventDEATH {
+			pb.Marks = append(pb.Marks, timelineMark{pb.Frame, markDEATH})
+		}
+	}
+	if pb.Sim.State == stateNEXTLEVEL {
+		pb.Marks = append(pb.Marks, timelineMark{pb.Frame, markLEVEL})
+	}
+}
+
+func (pb *runPlayback) restore(k playbackKeyframe) {
+	if err := pb.Sim.UnmarshalBinary(k.State); err != nil {
+		panic(err) // the state was saved by the same simulation
+	}
+	pb.Frame = k.Frame
+	pb.actions, pb.restarts = k.actions, k.restarts
+	pb.startScore = k.startScore
+	pb.start = append(pb.start[:0], k.start...)
+}
+
+// Seek goes to a frame of the run, without sound.
+func (pb *runPlayback) Seek(frame int) {
+	if frame < 0 {
+		frame = 0
+	} else if frame > pb.Length {
+		frame = pb.Length
+	}
+
+	// only go back to a keyframe when it is closer than where the playback is now
+	k := pb.Keyframes[minInt(frame/playbackKeyframeInterval, len(pb.Keyframes)-1)]
+	if frame < pb.Frame || k.Frame > pb.Frame {
+		pb.restore(k)
+	}
+
+	silent := pb.Sim.Silent
+	pb.Sim.Silent = true
+	for pb.Frame < frame {
+		pb.Step()
+	}
+	pb.Sim.Silent = silent
+}
@@ -0,0 +1,215 @@
+//go:build !headless
+// +build !headless
+
+package main
+
+import (
+	"fmt"
+	"image/color"
+
+	"github.com/askeladdk/pancake"
+	"github.com/askeladdk/pancake/input"
+	"github.com/askeladdk/pancake/mathx"
+	"github.com/askeladdk/pancake/text"
+)
+
+var playbackSpeeds = []float64{0.25, 0.5, 1, 2, 4, 8}
+
+// digitKeys jump to tenths of the run.
+var digitKeys = []input.Key{
+	input.Key0, input.Key1, input.Key2, input.Key3, input.Key4,
+	input.Key5, input.Key6, input.Key7, input.Key8, input.Key9,
+}
+
+const (
+	playbackNormalSpeed = 2   // index of 1x in playbackSpeeds
+	playbackSkip        = 300 // frames that left and right skip, 5 seconds
+	timelineMargin      = 8   // pixels between the timeline and the edges of the screen
+	timelineHeight      = 4   // pixels
+	timelineMarkHeight  = 10  // pixels
+)
+
+var (
+	timelineColor      = color.RGBA{0x40, 0x40, 0x40, 0xc0}
+	timelinePlayed     = color.RGBA{0xc0, 0xc0, 0xc0, 0xff}
+	timelineDeathColor = color.RGBA{0xff, 0x40, 0x40, 0xff}
+	timelineLevelColor = color.RGBA{0x40, 0xff, 0x60, 0xff}
+)
+
+// playbackScreen plays a recorded run with its own simulation.
+type playbackScreen struct {
+	*gameScreen
+	Playback *runPlayback
+	Help     *text.Text
+	Timeline solidRects
+	Speed    int     // index into playbackSpeeds
+	Time     float64 // frames played towards the next one
+	Paused   bool
+	Leave    bool
+}
+
+func newPlaybackScreen(res *resources, sess *session, l *runLog) *playbackScreen {
+	sim := newSimulation(res, l.Seed)
+	sim.Mute = sess.Sim.Mute
+	s := &playbackScreen{
+		gameScreen: newGameScreen(res, &session{Sim: sim}),
+		Playback:   newRunPlayback(l, sim),
+		Help:       text.NewText(res.Font12),
+		Timeline:   solidRects{Image: res.White},
+		Speed:      playbackNormalSpeed,
+	}
+	s.Help.Pos = mathx.Vec2{timelineMargin, res.Bounds.Max[1] - timelineMargin - timelineMarkHeight - 2*s.Help.LineHeight}
+	fmt.Fprintf(s.Help, "Space pauses, up and down change the speed, left and right skip, comma and period step,\n")
+	fmt.Fprintf(s.Help, "0-9 jump and Escape stops watching.")
+	if err := s.Playback.Err; err != nil {
+		fmt.Println("the run can only be played up to where", err)
+	}
+	return s
+}
+
+func (s *playbackScreen) Begin() {
+	s.Floaters.Clear()
+}
+
+func (s *playbackScreen) End() {}
+
+func (s *playbackScreen) Key(ev pancake.KeyEvent) error {
+	if !ev.Flags.Pressed() {
+		return nil
+	}
+
+	switch ev.Key {
+	case input.KeyEscape:
+		s.Leave = true
+	case input.KeySpace, input.KeyP:
+		s.Paused = !s.Paused
+		if s.Playback.Frame >= s.Playback.Length {
+			s.seek(0)
+		}
+	case input.KeyUp, input.KeyEqual:
+		s.Speed = minInt(s.Speed+1, len(playbackSpeeds)-1)
+	case input.KeyDown, input.KeyMinus:
+		s.Speed = maxInt(s.Speed-1, 0)
+	case input.KeyRight:
+		s.seek(s.Playback.Frame + playbackSkip)
+	case input.KeyLeft:
+		s.seek(s.Playback.Frame - playbackSkip)
+	case input.KeyPeriod:
+		s.Paused = true
+		s.seek(s.Playback.Frame + 1)
+	case input.KeyComma:
+		s.Paused = true
+		s.seek(s.Playback.Frame - 1)
+	case input.KeyHome:
+		s.seek(0)
+	case input.KeyEnd:
+		s.seek(s.Playback.Length)
+	default:
+		for i, key := range digitKeys {
+			if ev.Key == key {
+				s.seek(s.Playback.Length * i / 10)
+			}
+		}
+	}
+	return nil
+}
+
+// seek goes to a frame of the run.
+func (s *playbackScreen) seek(frame int) {
+	if frame == s.Playback.Frame+1 {
+		s.step()
+	} else {
+		s.Playback.Seek(frame)
+		s.Floaters.Clear()
+	}
+	s.Time = 0
+}
+
+// step plays the next frame.
+func (s *playbackScreen) step() {
+	if s.Playback.Frame >= s.Playback.Length {
+		return
+	}
+	s.Playback.Step()
+	s.Floaters.Frame(runFrameTime)
+	s.Floaters.AddEvents(s.Sim.Events)
+}
+
+func (s *playbackScreen) Frame(ev pancake.FrameEvent) (screenOp, error) {
+	if s.Leave {
+		return pop(), nil
+	}
+
+	if !s.Paused {
+		// the sounds of many frames at once are just noise
+		speed := playbackSpeeds[s.Speed]
+		s.Sim.Silent = speed > 1
+		for s.Time += speed; s.Time >= 1; s.Time-- {
+			s.step()
+		}
+		s.Sim.Silent = false
+		if s.Playback.Frame >= s.Playback.Length {
+			s.Paused, s.Time = true, 0
+		}
+	}
+
+	s.printStatus()
+	fmt.Fprintf(s.Text, "\n\n%s / %s  %gx", playbackClock(s.Playback.Frame), playbackClock(s.Playback.Length), playbackSpeeds[s.Speed])
+	if s.Paused {
+		fmt.Fprintf(s.Text, "  paused")
+	}
+	s.layoutTimeline()
+	return screenOp{}, nil
+}
+
+// playbackClock formats a number of frames as minutes and seconds.
+func playbackClock(frames int) string {
+	seconds := int(float64(frames) * runFrameTime)
+	return fmt.Sprintf("%d:%02d", seconds/60, seconds%60)
+}
+
+// layoutTimeline draws the timeline at the bottom of the screen with the deaths and level clears on it.
+func (s *playbackScreen) layoutTimeline() {
+	bounds := s.Res.Bounds
+	left, right := bounds.Min[0]+timelineMargin, bounds.Max[0]-timelineMargin
+	bottom := bounds.Max[1] - timelineMargin
+	x := func(frame int) float64 {
+		return left + (right-left)*float64(frame)/float64(maxInt(s.Playback.Length, 1))
+	}
+	bar := func(x0, x1, height float64, c color.Color) {
+		s.Timeline.Add(mathx.Rectangle{
+			Min: mathx.Vec2{x0, bottom - (timelineHeight+height)/2},
+			Max: mathx.Vec2{x1, bottom - (timelineHeight-height)/2},
+		}, c)
+	}
+
+	s.Timeline.Clear()
+	bar(left, right, timelineHeight, timelineColor)
+	bar(left, x(s.Playback.Frame), timelineHeight, timelinePlayed)
+	for _, m := range s.Playback.Marks {
+		c := timelineDeathColor
+		if m.Kind == markLEVEL {
+			c = timelineLevelColor
+		}
+		bar(x(m.Frame)-1, x(m.Frame)+1, timelineMarkHeight, c)
+	}
+	bar(x(s.Playback.Frame)-1, x(s.Playback.Frame)+1, timelineMarkHeight+4, color.White)
+}
+
+func (s *playbackScreen) Draw(ev pancake.DrawEvent) error {
+	// slow and paused playback moves between frames at its own pace
+	if s.Paused {
+		ev.Alpha = 1
+	} else if playbackSpeeds[s.Speed] < 1 {
+		ev.Alpha = s.Time
+	}
+
+	if err := s.gameScreen.Draw(ev); err != nil {
+		return err
+	}
+	s.Shader.Begin()
+	s.Drawer.Draw(&s.Timeline)
+	s.Drawer.Draw(s.Help)
+	s.Shader.End()
+	return nil
+}
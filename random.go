@@ -0,0 +1,25 @@
+package main
+
+// random is a splitmix64 pseudo random number generator.
+// Unlike math/rand its state is a single integer,
+// which makes it trivial to save and restore.
+type random struct {
+	State uint64
+}
+
+func (r *random) Seed(seed int64) {
+	r.State = uint64(seed)
+}
+
+func (r *random) Uint64() uint64 {
+	r.State += 0x9e3779b97f4a7c15
+	z := r.State
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
+}
+
+// Float64 returns a number in the half-open interval [0, 1).
+func (r *random) Float64() float64 {
+	return float64(r.Uint64()>>11) / (1 << 53)
+}
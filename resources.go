@@ -0,0 +1,78 @@
+//go:build !headless
+// +build !headless
+
+package main
+
+import (
+	"github.com/askeladdk/pancake/graphics"
+	"github.com/askeladdk/pancake/input"
+	"github.com/askeladdk/pancake/mathx"
+	"github.com/askeladdk/pancake/text"
+	"github.com/faiface/beep"
+)
+
+// resources are loaded once and shared by every screen and simulation.
+type resources struct {
+	Bindings     []*keyBindings // one per player
+	HighScores   *highScoreTable
+	Achievements *achievementStore
+	Toasts       *toaster
+	Versus       versusRules    // the rules of the last versus match
+	Feed         *spectatorFeed // publishes the game to spectators, if enabled
+	Drawer       *tintDrawer
+	Shader       *graphics.ShaderProgram
+	Font12       *text.Font
+	Font16       *text.Font
+	White        *graphics.Texture
+	Music        *beep.Ctrl
+	MusicLoop    *musicLoop
+	Sheet        *graphics.Texture
+	Images       []graphics.Image
+	Sounds       []*beep.Buffer
+	Reversed     []*beep.Buffer // the sounds played backwards
+	Rewind       bool           // whether the player can rewind time
+	Bounds       mathx.Rectangle
+	Background   staticImage
+	GameOver     staticImage
+	Title        staticImage
+	NextLevel    staticImage
+}
+
+// isPauseKey reports whether a key pauses the game for any of the players.
+func (res *resources) isPauseKey(key input.Key) bool {
+	if key == input.KeyEscape {
+		return true
+	}
+	for _, kb := range res.Bindings {
+		if a, ok := kb.Lookup(key); ok && a == bindPause {
+			return true
+		}
+	}
+	return false
+}
+
+// Midscreen returns the centre of the screen.
+func (res *resources) Midscreen() mathx.Vec2 {
+	return res.Bounds.Min.Add(res.Bounds.Max).Mul(0.5)
+}
+
+// newMenuText creates a text that is roughly centred on the screen.
+func (res *resources) newMenuText() *text.Text {
+	t := text.NewText(res.Font16)
+	t.Pos = res.Midscreen().Sub(mathx.Vec2{64, 3 * t.LineHeight})
+	return t
+}
+
+func newSimulation(res *resources, seed int64) *theSimulation {
+	s := &theSimulation{
+		simMedia: simMedia{
+			ImageAtlas: res.Sheet,
+			Images:     res.Images,
+			Sounds:     res.Sounds,
+			Reversed:   res.Reversed,
+		},
+		Bounds: res.Bounds,
+	}
+	s.NewGame(seed, 1)
+	return s
+}
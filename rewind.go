@@ -0,0 +1,104 @@
+package main
+
+import "bytes"
+
+// Rewinding takes the game back one frame at a time through the last few seconds while a key
+// is held. Only the state after the latest frame is kept whole. Every other frame is kept as the
+// compressed difference with the frame after it, so going back a frame applies its difference
+// to the state that is kept and the history holds little more than what changed.
+// The buffers are reused, so that keeping the history does not allocate once it is full.
+// A meter limits how long the player can rewind before it has to refill.
+
+const (
+	rewindHistory = 600  // frames that can be rewound, ten seconds
+	rewindMeter   = 180  // frames that a full meter rewinds, three seconds
+	rewindRefill  = 0.25 // meter that a frame of play refills, in frames
+)
+
+// rewindFrame is a frame in the history: the difference of the simulation after the frame
+// with the simulation after the next frame, the statistics of the run and the events of the frame.
+type rewindFrame struct {
+	Delta  []byte // empty for the latest frame
+	Size   int    // size of the saved simulation
+	Stats  runStats
+	Events []simEvent
+}
+
+// timeRewind keeps the history of a level and the meter that limits rewinding it.
+type timeRewind struct {
+	Frames [rewindHistory]rewindFrame
+	Head   int     // frames put
+	Count  int     // frames in the history
+	Meter  float64 // frames that can be rewound before the meter is empty
+	state  []byte  // the saved simulation after the latest frame
+	next   bytes.Buffer
+	writer saveWriter // writes to next
+	delta  deltaEncoder
+}
+
+// Clear forgets the history and fills the meter.
+func (r *timeRewind) Clear() {
+	r.Head, r.Count = 0, 0
+	r.Meter = rewindMeter
+	r.state = r.state[:0]
+}
+
+// Put adds the simulation and the statistics after a frame to the history and refills the meter a little.
+func (r *timeRewind) Put(sim *theSimulation, stats *runStats) {
+	r.next.Reset()
+	r.writer = saveWriter{w: &r.next}
+	if sim.writeState(&r.writer); r.writer.err != nil {
+		r.Clear()
+		return
+	}
+	state := r.next.Bytes()
+
+	if r.Count > 0 {
+		prev := &r.Frames[(r.Head-1)%rewindHistory]
+		prev.Delta = r.delta.Append(prev.Delta[:0], r.state, state)
+		prev.Size = len(r.state)
+	}
+
+	f := &r.Frames[r.Head%rewindHistory]
+	f.Delta = f.Delta[:0]
+	f.Size = len(state)
+	f.Stats = *stats
+	f.Events = append(f.Events[:0], sim.Events...)
+	r.state = append(r.state[:0], state...)
+	r.Head++
+	r.Count = minInt(r.Count+1, rewindHistory)
+
+	if r.Meter += rewindRefill; r.Meter > rewindMeter {
+		r.Meter = rewindMeter
+	}
+}
+
+// CanRewind reports whether there is a frame to go back to and meter to do it with.
+func (r *timeRewind) CanRewind() bool {
+	return r.Count > 1 && r.Meter >= 1
+}
+
+// Back undoes the last frame and returns its events, so that it can be heard backwards.
+func (r *timeRewind) Back(sim *theSimulation, stats *runStats) ([]simEvent, bool) {
+	if !r.CanRewind() {
+		return nil, false
+	}
+
+	f := &r.Frames[(r.Head-2)%rewindHistory]
+	state, err := decodeDelta(f.Delta, r.state, f.Size)
+	if err != nil {
+		return nil, false
+	} else if err := sim.UnmarshalBinary(state); err != nil {
+		return nil, false
+	}
+
+	undone := r.Frames[(r.Head-1)%rewindHistory].Events
+	f.Delta = f.Delta[:0]
+	*stats = f.Stats
+	r.state = append(r.state[:0], state...)
+	r.Head--
+	r.Count--
+	r.Meter--
+	sim.Actions = sim.Actions[:0]
+	return undone, true
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestRewindRestoresEarlierFrames(t *testing.T) {
+	sim := newBareSimulation(3)
+	sim.NewGame(3, 2)
+	sim.Reset()
+	bots := []*botController{
+		newBotController(sim, 0, botDifficulties["normal"], 1),
+		newBotController(sim, 1, botDifficulties["normal"], 2),
+	}
+
+	var stats runStats
+	var r timeRewind
+	r.Clear()
+	r.Put(sim, &stats)
+	step := func() {
+		for i, b := range bots {
+			b.Poll().Apply(sim, i)
+		}
+		sim.Frame(runFrameTime)
+		stats.Observe(sim, runFrameTime)
+		r.Put(sim, &stats)
+	}
+
+	for i := 0; i < rewindHistory+100; i++ {
+		step()
+	}
+	want, _ := sim.MarshalBinary()
+	wantStats := stats
+	for i := 0; i < 100; i++ {
+		step()
+	}
+
+	for i := 0; i < 100; i++ {
+		if _, ok := r.Back(sim, &stats); !ok {
+			t.Fatalf("could not go back %d frames", 1+i)
+		}
+	}
+	if got, _ := sim.MarshalBinary(); !bytes.Equal(got, want) {
+		t.Fatal("the game differs from the one 100 frames ago")
+	} else if stats.ShotsFired != wantStats.ShotsFired || stats.Hits != wantStats.Hits || stats.LevelTime != wantStats.LevelTime {
+		t.Fatalf("the statistics were not rewound: %+v", stats)
+	}
+
+	// only the differences are kept
+	size := len(r.state)
+	for _, f := range r.Frames {
+		size += len(f.Delta)
+	}
+	if full := rewindHistory * len(want); size > full*3/4 {
+		t.Fatalf("the history takes %d bytes, whole frames would take %d", size, full)
+	}
+
+	// keeping the history reuses its memory
+	if allocs := testing.AllocsPerRun(100, func() { r.Put(sim, &stats) }); allocs > 1 {
+		t.Fatalf("putting a frame allocates %.0f times", allocs)
+	}
+
+	// the game goes on from the rewound frame until the meter runs out
+	step()
+	n := 0
+	for ; n < rewindHistory; n++ {
+		if _, ok := r.Back(sim, &stats); !ok {
+			break
+		}
+	}
+	if n == 0 || n >= rewindMeter {
+		t.Fatalf("rewound %d frames with the meter at %.1f", n, r.Meter)
+	}
+}
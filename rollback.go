@@ -0,0 +1,180 @@
+package main
+
+// Rollback networking: the game does not wait for the input of the other player
+// but guesses it and carries on. When the real input turns out to be different,
+// the game goes back to the last tick that is known to be right and simulates
+// the ticks since then again. The game only waits when the guesses go too far back.
+
+const (
+	defaultRollbackDelay = 1
+	maxRollback          = 12              // ticks that can be simulated on guessed input
+	rollbackWindow       = maxRollback + 4 // snapshots that are kept to roll back to
+)
+
+type rollbackPeer struct {
+	inputExchange
+	Tick       int                             // next tick to simulate
+	Confirmed  int                             // ticks that were simulated on the real input of both players
+	snapshots  [rollbackWindow][]byte          // game states before the ticks
+	hashes     [rollbackWindow]uint64          // StateHash of the snapshots
+	events     [rollbackWindow][]simEvent      // events of the ticks, as they were last simulated
+	guesses    [rollbackWindow]controllerState // input of the other player that the ticks were simulated with
+	lastRemote controllerState                 // the last input of the other player that is known
+	past       *theSimulation                  // a confirmed tick that the game has moved past, to observe it
+}
+
+func newRollbackPeer(link *netLink) *rollbackPeer {
+	return &rollbackPeer{inputExchange: newInputExchange(link)}
+}
+
+func (p *rollbackPeer) Update(sim *theSimulation, local controllerState, step func([]controllerState), observe func(*theSimulation)) bool {
+	if !p.receive() {
+		return true
+	}
+
+	p.rollback(sim)
+	p.confirm(sim, observe)
+	p.schedule(p.Tick, local)
+
+	waiting := p.Tick-p.Confirmed >= maxRollback
+	if !waiting {
+		p.snapshot(sim, p.Tick)
+		step(p.inputs(p.Tick))
+		p.remember(sim, p.Tick)
+		p.Tick++
+		p.confirm(sim, observe)
+	}
+
+	p.send(p.Confirmed)
+	return waiting
+}
+
+// Settled reports whether no guessed input is part of the game state.
+func (p *rollbackPeer) Settled() bool {
+	return p.Confirmed == p.Tick
+}
+
+// guess returns the input of the other player for a tick.
+// Unknown input is guessed to be the same as the last one that is known,
+// except for buttons because a button is rarely pressed twice in a row.
+func (p *rollbackPeer) guess(tick int) controllerState {
+	buf := &p.Inputs[p.Other()]
+	if cs, ok := buf.Get(tick); ok {
+		return cs
+	}
+
+	cs := p.lastRemote
+	if last, ok := buf.Get(buf.Len() - 1); ok {
+		cs = last
+	}
+	cs.Pressed = 0
+	return cs
+}
+
+// inputs returns the input of both players for a tick and remembers the guess.
+func (p *rollbackPeer) inputs(tick int) []controllerState {
+	states := make([]controllerState, len(p.Inputs))
+	states[p.Me()], _ = p.Inputs[p.Me()].Get(tick)
+	states[p.Other()] = p.guess(tick)
+	p.guesses[tick%rollbackWindow] = states[p.Other()]
+	return states
+}
+
+func (p *rollbackPeer) snapshot(sim *theSimulation, tick int) {
+	p.snapshots[tick%rollbackWindow], _ = sim.MarshalBinary()
+	p.hashes[tick%rollbackWindow] = sim.StateHash()
+}
+
+// remember keeps the events of a tick until it is confirmed.
+func (p *rollbackPeer) remember(sim *theSimulation, tick int) {
+	p.events[tick%rollbackWindow] = append(p.events[tick%rollbackWindow][:0], sim.Events...)
+}
+
+// rollback simulates the ticks again from the first one that was simulated on a wrong guess.
+// Sounds that were already heard the first time are kept silent, only those of new events are played.
+func (p *rollbackPeer) rollback(sim *theSimulation) {
+	from := p.Confirmed
+	for ; from < p.Tick; from++ {
+		if cs, ok := p.Inputs[p.Other()].Get(from); !ok {
+			return
+		} else if cs != p.guesses[from%rollbackWindow] {
+			break
+		}
+	}
+	if from == p.Tick {
+		return
+	}
+
+	if err := sim.UnmarshalBinary(p.snapshots[from%rollbackWindow]); err != nil {
+		p.Link.Err = err
+		return
+	}
+
+	var missed []int
+	sim.Silent = true
+	for t := from; t < p.Tick; t++ {
+		if t > from {
+			p.snapshot(sim, t)
+		}
+		netAdvance(sim, p.inputs(t))
+		missed = append(missed, newSounds(p.events[t%rollbackWindow], sim.Events)...)
+		p.remember(sim, t)
+	}
+	sim.Silent = false
+
+	for _, snd := range missed {
+		sim.PlaySound(snd)
+	}
+}
+
+// newSounds returns the sounds of the events that a tick has after it was simulated again
+// and did not have before.
+func newSounds(before, after []simEvent) []int {
+	var heard [numEventCodes]int
+	for _, e := range before {
+		heard[e.Code]++
+	}
+
+	var sounds []int
+	for _, e := range after {
+		if heard[e.Code] > 0 {
+			heard[e.Code]--
+		} else if snd, ok := eventSounds[e.Code]; ok {
+			sounds = append(sounds, snd)
+		}
+	}
+	return sounds
+}
+
+// confirm marks the ticks that were simulated on the real input of the other player as final
+// and observes them. The hash and the state of a tick are those of the game after it.
+func (p *rollbackPeer) confirm(sim *theSimulation, observe func(*theSimulation)) {
+	for ; p.Confirmed < p.Tick; p.Confirmed++ {
+		cs, ok := p.Inputs[p.Other()].Get(p.Confirmed)
+		if !ok || cs != p.guesses[p.Confirmed%rollbackWindow] {
+			return
+		}
+		p.lastRemote = cs
+
+		if next := p.Confirmed + 1; next == p.Tick {
+			p.record(p.Confirmed, sim.StateHash())
+			observe(sim)
+		} else {
+			p.record(p.Confirmed, p.hashes[next%rollbackWindow])
+			observe(p.after(p.Confirmed))
+		}
+	}
+}
+
+// after returns the game as it was after a tick that the game has moved past,
+// from the snapshot of the next tick.
+func (p *rollbackPeer) after(tick int) *theSimulation {
+	if p.past == nil {
+		p.past = newBareSimulation(0)
+	}
+	if err := p.past.UnmarshalBinary(p.snapshots[(tick+1)%rollbackWindow]); err != nil {
+		p.Link.Err = err
+	}
+	p.past.Events = append(p.past.Events[:0], p.events[tick%rollbackWindow]...)
+	return p.past
+}
@@ -0,0 +1,80 @@
+//go:build !headless
+// +build !headless
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/askeladdk/pancake"
+	"github.com/askeladdk/pancake/graphics"
+	"github.com/askeladdk/pancake/input"
+	"github.com/askeladdk/pancake/text"
+)
+
+// roundEndScreen announces the winner of a versus round, and of the match once it is decided.
+type roundEndScreen struct {
+	*session
+	Res        *resources
+	Text       *text.Text
+	Background staticImage
+	Drawer     *tintDrawer
+	Shader     *graphics.ShaderProgram
+	Continue   bool
+}
+
+func newRoundEndScreen(res *resources, sess *session) *roundEndScreen {
+	return &roundEndScreen{
+		Res:        res,
+		session:    sess,
+		Text:       res.newMenuText(),
+		Background: res.Background,
+		Drawer:     res.Drawer,
+		Shader:     res.Shader,
+	}
+}
+
+func (s *roundEndScreen) Begin() {
+	s.Continue = false
+
+	s.Text.Clear()
+	if s.Sim.RoundWinner >= 0 {
+		fmt.Fprintf(s.Text, "Player %d wins round %d!\n\n", 1+s.Sim.RoundWinner, 1+s.Sim.Level)
+	} else {
+		fmt.Fprintf(s.Text, "Round %d is a draw.\n\n", 1+s.Sim.Level)
+	}
+	printStandings(s.Text, s.Sim)
+
+	if winner := s.Sim.MatchWinner(); winner >= 0 {
+		fmt.Fprintf(s.Text, "\nPlayer %d wins the match!\n", 1+winner)
+	}
+	fmt.Fprintf(s.Text, "\nPress Enter to continue.")
+}
+
+func (s *roundEndScreen) End() {}
+
+func (s *roundEndScreen) Key(ev pancake.KeyEvent) error {
+	if ev.Flags.Pressed() && ev.Key == input.KeyEnter {
+		s.Continue = true
+	}
+	return nil
+}
+
+func (s *roundEndScreen) Frame(ev pancake.FrameEvent) (screenOp, error) {
+	if !s.Continue {
+		return screenOp{}, nil
+	} else if s.Sim.MatchWinner() >= 0 {
+		return replaceAll(newTitleScreen(s.Res, s.session)), nil
+	}
+
+	s.Sim.Level++
+	return replace(newRoundStartScreen(s.Res, s.session)), nil
+}
+
+func (s *roundEndScreen) Draw(ev pancake.DrawEvent) error {
+	s.Shader.Begin()
+	s.Drawer.Draw(s.Background)
+	s.Drawer.Draw(s.Text)
+	s.Shader.End()
+	return nil
+}
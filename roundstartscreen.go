@@ -0,0 +1,88 @@
+//go:build !headless
+// +build !headless
+
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/askeladdk/pancake"
+	"github.com/askeladdk/pancake/graphics"
+	"github.com/askeladdk/pancake/input"
+	"github.com/askeladdk/pancake/text"
+)
+
+// roundStartScreen introduces a round of a versus match.
+type roundStartScreen struct {
+	*session
+	Res        *resources
+	Text       *text.Text
+	Background staticImage
+	Drawer     *tintDrawer
+	Shader     *graphics.ShaderProgram
+	Start      bool
+	Quit       bool
+}
+
+func newRoundStartScreen(res *resources, sess *session) *roundStartScreen {
+	return &roundStartScreen{
+		Res:        res,
+		session:    sess,
+		Text:       res.newMenuText(),
+		Background: res.Background,
+		Drawer:     res.Drawer,
+		Shader:     res.Shader,
+	}
+}
+
+func (s *roundStartScreen) Begin() {
+	s.Start = false
+	s.Quit = false
+
+	s.Text.Clear()
+	fmt.Fprintf(s.Text, "Round %d\n\n", 1+s.Sim.Level)
+	printStandings(s.Text, s.Sim)
+	fmt.Fprintf(s.Text, "\nFirst to %d wins.\n", s.Sim.Rules.RoundsToWin)
+	fmt.Fprintf(s.Text, "Press Enter to fight or ESC to give up.")
+}
+
+func (s *roundStartScreen) End() {}
+
+func (s *roundStartScreen) Key(ev pancake.KeyEvent) error {
+	if !ev.Flags.Pressed() {
+		return nil
+	}
+
+	switch ev.Key {
+	case input.KeyEnter:
+		s.Start = true
+	case input.KeyEscape:
+		s.Quit = true
+	}
+	return nil
+}
+
+func (s *roundStartScreen) Frame(ev pancake.FrameEvent) (screenOp, error) {
+	if s.Quit {
+		return replaceAll(newTitleScreen(s.Res, s.session)), nil
+	} else if s.Start {
+		return replace(newGameScreen(s.Res, s.session)), nil
+	}
+	return screenOp{}, nil
+}
+
+func (s *roundStartScreen) Draw(ev pancake.DrawEvent) error {
+	s.Shader.Begin()
+	s.Drawer.Draw(s.Background)
+	s.Drawer.Draw(s.Text)
+	s.Shader.End()
+	return nil
+}
+
+// printStandings writes the number of rounds that every player has won.
+func printStandings(w io.Writer, sim *theSimulation) {
+	for i, p := range sim.Players {
+		fmt.Fprintf(w, "Player %d: %d wins\n", 1+i, p.Wins)
+	}
+}
@@ -0,0 +1,421 @@
+package main
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// A run log records everything that the players did during a game so that anyone can play it again
+// to check the final score. It is signed so that edits are noticed, but the key is part of the game
+// and can be found by anyone who looks hard enough. What makes a score trustworthy is that playing
+// the log again without a window must end the game with that score, using only inputs that the game could have produced.
+//
+// The file starts with a magic number and the format version, followed by the compressed log
+// and the signature of everything before it. Version 2 added fixed-point mode.
+const (
+	runLogMagic   = "ASTL"
+	runLogVersion = 2
+	maxRunLogSize = 64 << 20         // largest uncompressed log, well over an hour of play
+	runFrameTime  = 1.0 / 60         // the simulation runs at a steady 60 frames per second
+	maxRunFrames  = 4 * 60 * 60 * 60 // longest run that is verified, four hours
+)
+
+// runLogKey signs the run logs. Release builds set their own with
+//
+//	go build -ldflags "-X main.runLogKey=..."
+//
+// The default key is in the source, so logs signed with it can be forged by anyone.
+var runLogKey = defaultRunLogKey
+
+const defaultRunLogKey = "asteroids run log"
+
+var (
+	errBadRunLog    = errors.New("not an asteroids run log")
+	errRunSignature = errors.New("the run log has been changed since it was written")
+)
+
+// runAction is an action that a player took during a frame of the run.
+type runAction struct {
+	Frame  int
+	Player int
+	Code   actionCode
+	Value  float64
+}
+
+// runLog is the record of a run.
+type runLog struct {
+	Date     time.Time
+	Seed     int64
+	Players  int
+	Frames   int   // frames that were simulated
+	Score    int   // final score
+	Level    int   // final level, counting from 0
+	Fixed    bool  // the game was simulated in fixed-point mode
+	Restarts []int // frames before which the level was started over
+	Actions  []runAction
+}
+
+// MarshalBinary encodes and signs the run log.
+func (l *runLog) MarshalBinary() ([]byte, error) {
+	var body bytes.Buffer
+	fw, _ := flate.NewWriter(&body, flate.BestCompression)
+	w := saveWriter{w: fw}
+	w.i64(l.Date.Unix())
+	w.i64(l.Seed)
+	w.u32(uint32(l.Players))
+	w.u32(uint32(l.Frames))
+	w.i64(int64(l.Score))
+	w.i64(int64(l.Level))
+	w.bool(l.Fixed)
+	w.u32(uint32(len(l.Restarts)))
+	for _, f := range l.Restarts {
+		w.u32(uint32(f))
+	}
+	w.u32(uint32(len(l.Actions)))
+	for _, a := range l.Actions {
+		w.u32(uint32(a.Frame))
+		w.u32(uint32(a.Player))
+		w.u32(uint32(a.Code))
+		w.f64(a.Value)
+	}
+	if w.err != nil {
+		return nil, w.err
+	} else if err := fw.Close(); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	w = saveWriter{w: &buf}
+	w.bytes([]byte(runLogMagic))
+	w.u32(runLogVersion)
+	w.bytes(body.Bytes())
+	w.bytes(signRunLog(buf.Bytes()))
+	return buf.Bytes(), w.err
+}
+
+// UnmarshalBinary checks the signature of a run log and decodes it.
+func (l *runLog) UnmarshalBinary(data []byte) error {
+	if len(data) < len(runLogMagic)+4+sha256.Size || string(data[:len(runLogMagic)]) != runLogMagic {
+		return errBadRunLog
+	}
+
+	signed, signature := data[:len(data)-sha256.Size], data[len(data)-sha256.Size:]
+	if !hmac.Equal(signature, signRunLog(signed)) {
+		return errRunSignature
+	}
+
+	r := saveReader{r: bytes.NewReader(signed[len(runLogMagic):])}
+	version := r.u32()
+	if r.err == nil && (version < 1 || version > runLogVersion) {
+		return fmt.Errorf("unsupported run log version %d", version)
+	}
+
+	fr := flate.NewReader(r.r)
+	defer fr.Close()
+	body, err := io.ReadAll(io.LimitReader(fr, maxRunLogSize+1))
+	if err != nil || len(body) > maxRunLogSize {
+		return errBadRunLog
+	}
+
+	r = saveReader{r: bytes.NewReader(body)}
+	l.Date = time.Unix(r.i64(), 0)
+	l.Seed = r.i64()
+	l.Players = int(r.u32())
+	l.Frames = int(r.u32())
+	l.Score = int(r.i64())
+	l.Level = int(r.i64())
+	l.Fixed = version >= 2 && r.bool()
+	l.Restarts = make([]int, r.count())
+	for i := range l.Restarts {
+		l.Restarts[i] = int(r.u32())
+	}
+	l.Actions = make([]runAction, r.count())
+	for i := range l.Actions {
+		l.Actions[i] = runAction{
+			Frame:  int(r.u32()),
+			Player: int(r.u32()),
+			Code:   actionCode(r.u32()),
+			Value:  r.f64(),
+		}
+	}
+	if r.err != nil || r.r.Len() != 0 {
+		return errBadRunLog
+	} else if l.Frames > maxRunFrames {
+		return fmt.Errorf("the run is longer than %d frames", maxRunFrames)
+	}
+	return nil
+}
+
+func signRunLog(data []byte) []byte {
+	mac := hmac.New(sha256.New, []byte(runLogKey))
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+func loadRunLog(filename string) (*runLog, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var l runLog
+	if err := l.UnmarshalBinary(data); err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
+
+// runReplay plays a run log again one frame at a time.
+type runReplay struct {
+	Log        *runLog
+	Sim        *theSimulation
+	Frame      int // next frame to simulate
+	actions    int // next action to take
+	restarts   int // next restart to make
+	startScore int // the score and players at the start of the level, for restarts
+	start      []player
+}
+
+func newRunReplay(l *runLog, sim *theSimulation) *runReplay {
+	rp := &runReplay{Log: l, Sim: sim}
+	rp.Reset()
+	return rp
+}
+
+// Reset goes back to the start of the run.
+func (rp *runReplay) Reset() {
+	rp.Sim.Fixed = rp.Log.Fixed
+	rp.Sim.NewGame(rp.Log.Seed, rp.Log.Players)
+	rp.Sim.Reset()
+	rp.Frame, rp.actions, rp.restarts = 0, 0, 0
+	rp.beginLevel()
+}
+
+func (rp *runReplay) beginLevel() {
+	rp.startScore = rp.Sim.Score
+	rp.start = append(rp.start[:0], rp.Sim.Players...)
+}
+
+// Done reports whether every frame of the run has been simulated.
+func (rp *runReplay) Done() bool {
+	return rp.Frame >= rp.Log.Frames
+}
+
+// Step simulates the next frame and returns why it cannot have happened, if it cannot.
+func (rp *runReplay) Step() error {
+	l, sim, frame := rp.Log, rp.Sim, rp.Frame
+	switch sim.State {
+	case stateGAMEOVER:
+		return fmt.Errorf("the game was over at frame %d but the log goes on", frame)
+	case stateNEXTLEVEL:
+		sim.Level++
+		sim.Reset()
+		rp.beginLevel()
+	}
+
+	for ; rp.restarts < len(l.Restarts) && l.Restarts[rp.restarts] <= frame; rp.restarts++ {
+		if l.Restarts[rp.restarts] < frame {
+			return fmt.Errorf("the restarts are out of order at frame %d", frame)
+		}
+		sim.Score = rp.startScore
+		sim.Players = append(sim.Players[:0], rp.start...)
+		sim.Reset()
+	}
+
+	var taken [maxPlayers][actionHyperspace + 1]bool
+	for ; rp.actions < len(l.Actions) && l.Actions[rp.actions].Frame <= frame; rp.actions++ {
+		a := l.Actions[rp.actions]
+		if err := checkRunAction(sim, a, frame, &taken); err != nil {
+			return err
+		}
+		sim.Action(sim.Ship(a.Player), a.Code, a.Value)
+	}
+
+	sim.Frame(runFrameTime)
+	rp.Frame++
+	return nil
+}
+
+// latestRunLog returns the path of the newest run log in the user directory.
+func latestRunLog() (string, error) {
+	dir, err := userFilePath("")
+	if err != nil {
+		return "", err
+	}
+
+	// the names start with the date, so the last one is the newest
+	names, err := filepath.Glob(filepath.Join(dir, "run-*.run"))
+	if err != nil {
+		return "", err
+	} else if len(names) == 0 {
+		return "", errors.New("no run has been logged yet")
+	}
+	sort.Strings(names)
+	return names[len(names)-1], nil
+}
+
+// Verify plays the run again and returns why it cannot have happened, if it cannot.
+func (l *runLog) Verify() error {
+	if l.Players < 1 || l.Players > maxPlayers {
+		return fmt.Errorf("a game cannot have %d players", l.Players)
+	}
+
+	rp := newRunReplay(l, newBareSimulation(l.Seed))
+	for !rp.Done() {
+		if err := rp.Step(); err != nil {
+			return err
+		}
+	}
+
+	sim := rp.Sim
+	if rp.actions < len(l.Actions) || rp.restarts < len(l.Restarts) {
+		return fmt.Errorf("the log has inputs after the last frame")
+	} else if sim.State != stateGAMEOVER {
+		return fmt.Errorf("the game is not over after %d frames", l.Frames)
+	} else if sim.Score != l.Score {
+		return fmt.Errorf("the log claims a score of %d but the game ended with %d", l.Score, sim.Score)
+	} else if sim.Level != l.Level {
+		return fmt.Errorf("the log claims level %d but the game ended on level %d", 1+l.Level, 1+sim.Level)
+	}
+	return nil
+}
+
+// checkRunAction returns why an action could not have been taken during a frame, if it could not.
+// A controller takes every kind of action at most once per frame and only within its range.
+func checkRunAction(sim *theSimulation, a runAction, frame int, taken *[maxPlayers][actionHyperspace + 1]bool) error {
+	if a.Frame < frame {
+		return fmt.Errorf("the actions are out of order at frame %d", frame)
+	} else if a.Player < 0 || a.Player >= len(sim.Players) {
+		return fmt.Errorf("frame %d has an action of player %d, who is not in the game", frame, 1+a.Player)
+	} else if a.Code < actionForward || a.Code > actionHyperspace {
+		return fmt.Errorf("frame %d has an unknown action %d", frame, a.Code)
+	} else if taken[a.Player][a.Code] {
+		return fmt.Errorf("player %d took the same action twice in frame %d", 1+a.Player, frame)
+	} else if sim.Ship(a.Player) < 0 {
+		return fmt.Errorf("player %d has no ship to act with in frame %d", 1+a.Player, frame)
+	}
+	taken[a.Player][a.Code] = true
+
+	lo, hi := 0.0, 0.0
+	switch a.Code {
+	case actionForward:
+		lo, hi = 0, 1
+	case actionTurn:
+		lo, hi = -1, 1
+	}
+	if math.IsNaN(a.Value) || a.Value < lo || a.Value > hi {
+		return fmt.Errorf("player %d took an action with the impossible value %g in frame %d", 1+a.Player, a.Value, frame)
+	}
+	return nil
+}
+
+// runRecorder writes down a local game while it is played.
+// Runs that are changed in ways that cannot be played again, such as loading a quick save, are not logged.
+type runRecorder struct {
+	Log    runLog
+	Broken string // why the run cannot be logged, if it cannot
+	File   string // where the log was saved
+}
+
+func newRunRecorder(seed int64, players int, fixedPoint bool) *runRecorder {
+	return &runRecorder{Log: runLog{
+		Date:    time.Now(),
+		Seed:    seed,
+		Players: players,
+		Fixed:   fixedPoint,
+	}}
+}
+
+// Record writes down the actions that are about to be simulated.
+func (rr *runRecorder) Record(sim *theSimulation, deltaTime float64) {
+	if math.Abs(deltaTime-runFrameTime) > 1e-9 {
+		rr.Break("the game did not run at a steady 60 frames per second")
+	}
+	for _, a := range sim.Actions {
+		rr.Log.Actions = append(rr.Log.Actions, runAction{
+			Frame:  rr.Log.Frames,
+			Player: sim.At(a.EntityID).Player,
+			Code:   a.Code,
+			Value:  a.Value,
+		})
+	}
+	rr.Log.Frames++
+}
+
+// Restart writes down that the level was started over.
+func (rr *runRecorder) Restart() {
+	rr.Log.Restarts = append(rr.Log.Restarts, rr.Log.Frames)
+}
+
+// Break stops the run from being logged.
+func (rr *runRecorder) Break(reason string) {
+	if rr.Broken == "" {
+		rr.Broken = reason
+	}
+}
+
+// Save writes the log of a finished run to a new file in the user directory and returns its path.
+func (rr *runRecorder) Save(sim *theSimulation) (string, error) {
+	if rr.Broken != "" {
+		return "", fmt.Errorf("the run was not logged because %s", rr.Broken)
+	}
+
+	rr.Log.Score = sim.Score
+	rr.Log.Level = sim.Level
+	data, err := rr.Log.MarshalBinary()
+	if err != nil {
+		return "", err
+	}
+
+	filename, err := userFilePath(fmt.Sprintf("run-%s-%d.run", rr.Log.Date.Format("20060102-150405"), rr.Log.Score))
+	if err != nil {
+		return "", err
+	} else if err := writeFileAtomic(filename, data); err != nil {
+		return "", err
+	}
+	rr.File = filename
+	return filename, nil
+}
+
+// verifyCommand plays run logs again and reports whether their scores can be trusted.
+func verifyCommand(args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: verify <run log>...")
+	}
+
+	if runLogKey == defaultRunLogKey {
+		fmt.Fprintln(out, "warning: this build signs run logs with the public default key, so a valid signature proves nothing")
+		fmt.Fprintln(out, "warning: only the replay of a run can be trusted, build with RUNLOG_KEY to sign run logs")
+	}
+
+	rejected := 0
+	for _, filename := range args {
+		l, err := loadRunLog(filename)
+		if err == nil {
+			err = l.Verify()
+		}
+
+		if err != nil {
+			rejected++
+			fmt.Fprintf(out, "%s: rejected: %v\n", filename, err)
+		} else {
+			fmt.Fprintf(out, "%s: ok, score %d on level %d by %d player(s) on %s\n",
+				filename, l.Score, 1+l.Level, l.Players, l.Date.Format("2006-01-02 15:04"))
+		}
+	}
+
+	if rejected > 0 {
+		return fmt.Errorf("%d of %d run logs rejected", rejected, len(args))
+	}
+	return nil
+}
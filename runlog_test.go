@@ -0,0 +1,33 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestVerifyWarnsAboutTheDefaultKey(t *testing.T) {
+	defer func(key string) { runLogKey = key }(runLogKey)
+
+	for _, key := range []string{defaultRunLogKey, "a key of our own"} {
+		runLogKey = key
+		var out bytes.Buffer
+		verifyCommand([]string{"missing.run"}, &out)
+		if warned := strings.Contains(out.String(), "default key"); warned != (key == defaultRunLogKey) {
+			t.Errorf("with key %q the output was %q", key, out.String())
+		}
+	}
+}
+
+func TestRunLogLengthIsLimited(t *testing.T) {
+	for _, frames := range []int{maxRunFrames, maxRunFrames + 1, 1<<32 - 1} {
+		data, err := (&runLog{Seed: 1, Players: 1, Frames: frames}).MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var l runLog
+		if err := l.UnmarshalBinary(data); (err == nil) != (frames <= maxRunFrames) {
+			t.Errorf("a log of %d frames: %v", frames, err)
+		}
+	}
+}
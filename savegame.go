@@ -0,0 +1,393 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+
+	"github.com/askeladdk/pancake/mathx"
+)
+
+// Save files start with a magic number followed by the format version.
+// Bump saveVersion whenever the layout below changes.
+// Version 2 added the seed, version 3 the combo state, version 4 the players,
+// version 5 the versus mode and version 6 fixed-point mode.
+const (
+	saveMagic   = "ASTR"
+	saveVersion = 6
+)
+
+var errBadSave = errors.New("not an asteroids save file")
+
+// MarshalBinary encodes the game state of the simulation.
+// Assets such as images and sounds are not included.
+func (s *theSimulation) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	w := saveWriter{w: &buf}
+	s.writeState(&w)
+	return buf.Bytes(), w.err
+}
+
+// writeState writes the encoding of MarshalBinary, so that the writer and its buffer can be reused.
+func (s *theSimulation) writeState(w *saveWriter) {
+	w.str(saveMagic)
+	w.u32(saveVersion)
+	w.u32(uint32(s.State))
+	w.i64(int64(s.Level))
+	w.i64(int64(s.Score))
+	w.i64(int64(s.Remaining))
+	w.i64(s.Seed)
+	w.u64(s.Rand.State)
+	w.i64(int64(s.Multiplier))
+	w.f64(s.ComboTime)
+	w.bool(s.Damaged)
+
+	w.u32(uint32(len(s.Players)))
+	for _, p := range s.Players {
+		w.i64(int64(p.Score))
+		w.i64(int64(p.Lives))
+		w.f64(p.Respawn)
+		w.i64(int64(p.Wins))
+	}
+
+	w.u32(uint32(s.Mode))
+	w.i64(int64(s.Rules.RoundsToWin))
+	w.i64(int64(s.Rules.Lives))
+	w.i64(int64(s.Rules.Asteroids))
+	w.bool(s.Rules.FriendlyFire)
+	w.i64(int64(s.RoundWinner))
+	w.bool(s.Fixed)
+
+	w.u32(uint32(len(s.Actions)))
+	for _, a := range s.Actions {
+		w.i64(int64(a.EntityID))
+		w.u32(uint32(a.Code))
+		w.f64(a.Value)
+	}
+
+	w.u32(uint32(len(s.Entities)))
+	for _, e := range s.Entities {
+		w.i64(int64(e.ImageID))
+		w.vec2(e.Pos)
+		w.vec2(e.Vel)
+		w.f64(e.Rot)
+		w.f64(e.RotV)
+		w.f64(e.Acc)
+		w.f64(e.RotA)
+		w.f64(e.MaxV)
+		w.f64(e.MinRotV)
+		w.f64(e.Turn)
+		w.f64(e.Thrust)
+		w.u32(e.Mask)
+		w.f64(e.Radius)
+		w.f64(e.Lifetime)
+		w.vec2(e.Pos0)
+		w.f64(e.Rot0)
+		w.i64(int64(e.Kills))
+		w.i64(int64(e.Player))
+	}
+}
+
+// UnmarshalBinary restores the game state encoded by MarshalBinary.
+// The simulation is left untouched if the data is invalid.
+func (s *theSimulation) UnmarshalBinary(data []byte) error {
+	r := saveReader{r: bytes.NewReader(data)}
+
+	if magic := r.bytes(len(saveMagic)); r.err != nil || string(magic) != saveMagic {
+		return errBadSave
+	}
+
+	version := r.u32()
+	if r.err != nil {
+		return errBadSave
+	} else if version < 1 || version > saveVersion {
+		return fmt.Errorf("unsupported save version %d", version)
+	}
+
+	state := gameState(r.u32())
+	level := int(r.i64())
+	score := int(r.i64())
+	remaining := int(r.i64())
+	var seed int64
+	if version >= 2 {
+		seed = r.i64()
+	}
+	rng := random{State: r.u64()}
+	multiplier, comboTime, damaged := 1, 0.0, false
+	if version >= 3 {
+		multiplier = int(r.i64())
+		comboTime = r.f64()
+		damaged = r.bool()
+	}
+
+	// older saves are always single player
+	players := []player{{Score: score, Lives: 1}}
+	if version >= 4 {
+		players = make([]player, r.count())
+		for i := range players {
+			players[i] = player{
+				Score:   int(r.i64()),
+				Lives:   int(r.i64()),
+				Respawn: r.f64(),
+			}
+			if version >= 5 {
+				players[i].Wins = int(r.i64())
+			}
+		}
+	}
+
+	mode, rules, roundWinner := modeCOOP, versusRules{}, -1
+	if version >= 5 {
+		mode = gameMode(r.u32())
+		rules.RoundsToWin = int(r.i64())
+		rules.Lives = int(r.i64())
+		rules.Asteroids = int(r.i64())
+		rules.FriendlyFire = r.bool()
+		roundWinner = int(r.i64())
+	}
+
+	fixedPoint := false
+	if version >= 6 {
+		fixedPoint = r.bool()
+	}
+
+	actions := make([]action, r.count())
+	for i := range actions {
+		actions[i] = action{
+			EntityID: int(r.i64()),
+			Code:     actionCode(r.u32()),
+			Value:    r.f64(),
+		}
+	}
+
+	entities := make([]entity, r.count())
+	for i := range entities {
+		e := &entities[i]
+		e.ImageID = int(r.i64())
+		e.Pos = r.vec2()
+		e.Vel = r.vec2()
+		e.Rot = r.f64()
+		e.RotV = r.f64()
+		e.Acc = r.f64()
+		e.RotA = r.f64()
+		e.MaxV = r.f64()
+		e.MinRotV = r.f64()
+		e.Turn = r.f64()
+		e.Thrust = r.f64()
+		e.Mask = r.u32()
+		e.Radius = r.f64()
+		e.Lifetime = r.f64()
+		e.Pos0 = r.vec2()
+		e.Rot0 = r.f64()
+		if version >= 3 {
+			e.Kills = int(r.i64())
+		}
+		if version >= 4 {
+			e.Player = int(r.i64())
+		}
+	}
+
+	if r.err != nil {
+		return r.err
+	} else if state < statePLAYING || state > stateROUNDOVER {
+		return errBadSave
+	} else if level < 0 || score < 0 || remaining < 0 {
+		return errBadSave
+	} else if multiplier < 1 || multiplier > maxMultiplier {
+		return errBadSave
+	} else if len(players) < 1 || len(players) > maxPlayers {
+		return errBadSave
+	} else if mode != modeCOOP && mode != modeVERSUS {
+		return errBadSave
+	} else if mode == modeVERSUS && !rules.Valid() {
+		return errBadSave
+	} else if roundWinner < -1 || roundWinner >= len(players) {
+		return errBadSave
+	}
+
+	for _, p := range players {
+		if p.Score < 0 || p.Lives < 0 || p.Wins < 0 {
+			return errBadSave
+		}
+	}
+
+	for _, e := range entities {
+		if e.ImageID < 0 || e.ImageID >= len(imageRects) {
+			return errBadSave
+		} else if e.Player < 0 || e.Player >= len(players) {
+			return errBadSave
+		}
+	}
+
+	for _, a := range actions {
+		if a.EntityID < 0 || a.EntityID >= len(entities) {
+			return errBadSave
+		}
+	}
+
+	s.State = state
+	s.Level = level
+	s.Score = score
+	s.Players = append(s.Players[:0], players...)
+	s.Mode = mode
+	s.Rules = rules
+	s.RoundWinner = roundWinner
+	s.Fixed = fixedPoint
+	s.Remaining = remaining
+	s.Seed = seed
+	s.Rand = rng
+	s.Multiplier = multiplier
+	s.ComboTime = comboTime
+	s.Damaged = damaged
+	s.Events = s.Events[:0]
+	s.Actions = append(s.Actions[:0], actions...)
+	s.Entities = append(s.Entities[:0], entities...)
+	return nil
+}
+
+// SaveFile writes the game state to a file, replacing it atomically.
+func (s *theSimulation) SaveFile(filename string) error {
+	data, err := s.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filename, data)
+}
+
+// LoadFile restores the game state from a file written by SaveFile.
+func (s *theSimulation) LoadFile(filename string) error {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	return s.UnmarshalBinary(data)
+}
+
+// userFilePath returns the path of a file in the per-user data directory,
+// creating the directory if it does not exist.
+func userFilePath(name string) (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	dir = filepath.Join(dir, "asteroids")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name), nil
+}
+
+// writeFileAtomic writes to a temporary file first and renames it over the target,
+// so that a crash halfway never leaves a truncated file behind.
+func writeFileAtomic(filename string, data []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(filename), filepath.Base(filename)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	w := bufio.NewWriter(f)
+	if _, err := w.Write(data); err != nil {
+		f.Close()
+		return err
+	} else if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	} else if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	} else if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), filename)
+}
+
+type saveWriter struct {
+	w   io.Writer
+	err error
+	buf [8]byte
+}
+
+func (w *saveWriter) bytes(p []byte) {
+	if w.err == nil {
+		_, w.err = w.w.Write(p)
+	}
+}
+
+func (w *saveWriter) str(s string) {
+	if w.err == nil {
+		_, w.err = io.WriteString(w.w, s)
+	}
+}
+
+func (w *saveWriter) u32(v uint32) {
+	binary.LittleEndian.PutUint32(w.buf[:4], v)
+	w.bytes(w.buf[:4])
+}
+
+func (w *saveWriter) u64(v uint64) {
+	binary.LittleEndian.PutUint64(w.buf[:8], v)
+	w.bytes(w.buf[:8])
+}
+
+func (w *saveWriter) i64(v int64)   { w.u64(uint64(v)) }
+func (w *saveWriter) f64(v float64) { w.u64(math.Float64bits(v)) }
+
+func (w *saveWriter) bool(v bool) {
+	if v {
+		w.u32(1)
+	} else {
+		w.u32(0)
+	}
+}
+
+func (w *saveWriter) vec2(v mathx.Vec2) {
+	w.f64(v[0])
+	w.f64(v[1])
+}
+
+type saveReader struct {
+	r   *bytes.Reader
+	err error
+}
+
+func (r *saveReader) bytes(n int) []byte {
+	p := make([]byte, n)
+	if r.err == nil {
+		if _, err := io.ReadFull(r.r, p); err != nil {
+			r.err = errBadSave
+		}
+	}
+	return p
+}
+
+func (r *saveReader) u32() uint32  { return binary.LittleEndian.Uint32(r.bytes(4)) }
+func (r *saveReader) u64() uint64  { return binary.LittleEndian.Uint64(r.bytes(8)) }
+func (r *saveReader) i64() int64   { return int64(r.u64()) }
+func (r *saveReader) f64() float64 { return math.Float64frombits(r.u64()) }
+func (r *saveReader) bool() bool   { return r.u32() != 0 }
+func (r *saveReader) vec2() (v mathx.Vec2) {
+	v[0] = r.f64()
+	v[1] = r.f64()
+	return v
+}
+
+// count reads a slice length and guards against absurd values
+// so that a corrupt file cannot trigger a huge allocation.
+func (r *saveReader) count() int {
+	n := int(r.u32())
+	if r.err == nil && n > r.r.Len() {
+		r.err = errBadSave
+	}
+	if r.err != nil {
+		return 0
+	}
+	return n
+}
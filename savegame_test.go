@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+// mashControls turns, thrusts and fires in a pattern that repeats every second.
+func mashControls(frame int) controllerState {
+	cs := controllerState{Turn: float64(frame/20%3 - 1), Thrust: float64(frame / 30 % 2)}
+	if frame%15 == 0 {
+		cs.Pressed = buttonFire
+	}
+	return cs
+}
+
+// stepGame applies the input of a frame and simulates it, going on to the next level like the game screen.
+func stepGame(sim *theSimulation, frame int) {
+	if sim.State == stateNEXTLEVEL {
+		sim.Level++
+		sim.Reset()
+	}
+	mashControls(frame).Apply(sim, 0)
+	sim.Frame(runFrameTime)
+}
+
+func TestResumedGameMatchesUninterrupted(t *testing.T) {
+	const saveAt, playFor = 300, 600
+
+	sim := newBareSimulation(42)
+	sim.NewGame(42, 1)
+	sim.Reset()
+	for frame := 0; frame < saveAt; frame++ {
+		stepGame(sim, frame)
+	}
+
+	// save with the actions of the next frame pending
+	mashControls(saveAt).Apply(sim, 0)
+	data, err := sim.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	resumed := newBareSimulation(1)
+	if err := resumed.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	sim.Frame(runFrameTime)
+	resumed.Frame(runFrameTime)
+
+	for frame := saveAt + 1; frame <= saveAt+playFor; frame++ {
+		if a, b := sim.StateHash(), resumed.StateHash(); a != b {
+			t.Fatalf("frame %d: the resumed game differs, %016x != %016x", frame, b, a)
+		} else if sim.State == stateGAMEOVER {
+			t.Fatalf("frame %d: the game ended too soon to compare", frame)
+		}
+		stepGame(sim, frame)
+		stepGame(resumed, frame)
+	}
+}
+
+func TestCorruptSaveIsRejected(t *testing.T) {
+	sim := newBareSimulation(1)
+	sim.Reset()
+	data, err := sim.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the level follows the magic number, the version and the state
+	offset := len(saveMagic) + 4 + 4
+	binary.LittleEndian.PutUint64(data[offset:], ^uint64(0))
+
+	other := newBareSimulation(2)
+	if err := other.UnmarshalBinary(data); err != errBadSave {
+		t.Fatalf("a save with level -1 was accepted: %v", err)
+	}
+	if other.Seed != 2 {
+		t.Fatal("a rejected save changed the simulation")
+	}
+}
+
+func TestLoadStartsTheStatisticsOver(t *testing.T) {
+	idle := &scriptedController{Script: func(int) controllerState { return controllerState{} }}
+	pilot := &scriptedController{Script: mashControls}
+	sess := newSession(newBareSimulation(1), []controller{pilot, idle}, nil)
+	sess.NewGame(1, 1)
+	sess.Sim.Reset()
+	data, err := sess.Sim.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sess.Stats.ShotsFired = 10
+	sess.Achievements.Level[counterSHOTS] = 10
+	if err := sess.Load(data); err != nil {
+		t.Fatal(err)
+	} else if sess.Stats.ShotsFired != 0 || sess.Achievements.Level[counterSHOTS] != 0 {
+		t.Fatal("the statistics of the abandoned game were kept")
+	}
+
+	sess.NewGame(1, 2)
+	if err := sess.Load(data); err != errOtherGame {
+		t.Fatalf("loaded a one player save into a two player game: %v", err)
+	} else if len(sess.Sim.Players) != 2 {
+		t.Fatal("the rejected save was restored")
+	}
+
+	sess.NewMatch(1, 1, defaultVersusRules())
+	if err := sess.Load(data); err != errOtherGame {
+		t.Fatalf("loaded a cooperative save into a versus match: %v", err)
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import "github.com/askeladdk/pancake/mathx"
+
+const (
+	pointsAsteroid  = 100
+	pointsDebris    = 25
+	pointsShot      = -5
+	pointsMultiKill = 50  // for every kill after the first by the same bullet
+	pointsFlawless  = 500 // for clearing a level without losing a ship or using hyperspace
+	maxMultiplier   = 8
+	comboDecayTime  = 1.5 // seconds until the multiplier drops by one
+)
+
+// addScore changes the score of a player and records it as an event at the position where it happened.
+// The score never goes below zero.
+func (s *theSimulation) addScore(p int, points int, pos mathx.Vec2) {
+	pl := &s.Players[p]
+	if pl.Score+points < 0 {
+		points = -pl.Score
+	}
+	pl.Score += points
+	s.Score += points
+	s.emit(eventSCORE, pos, points)
+}
+
+// kill scores the destruction of target by bullet.
+// Quick successive kills raise the multiplier, and so do multiple kills by the same bullet,
+// which goes through everything it touches in the frame of its first hit.
+func (s *theSimulation) kill(bullet, target *entity, points int) {
+	bullet.Kills++
+	if bullet.Kills == 1 {
+		s.emit(eventHIT, bullet.Pos, 0)
+	}
+	s.emit(eventDESTROYED, target.Pos, int(target.Mask&(flagASTEROID|flagDEBRIS)))
+
+	if bullet.Kills > 1 {
+		points += pointsMultiKill * (bullet.Kills - 1)
+	}
+
+	s.addScore(bullet.Player, points*s.Multiplier, target.Pos)
+
+	if s.Multiplier < maxMultiplier {
+		s.Multiplier++
+	}
+	s.ComboTime = comboDecayTime
+}
+
+func (s *theSimulation) processCombo(deltaTime float64) {
+	if s.Multiplier <= 1 {
+		return
+	}
+
+	s.ComboTime -= deltaTime
+	if s.ComboTime <= 0 {
+		s.Multiplier--
+		s.ComboTime = comboDecayTime
+	}
+}
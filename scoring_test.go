@@ -0,0 +1,53 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/askeladdk/pancake/mathx"
+)
+
+// clearWithOneShot leaves the ship alone on the field with four pieces of debris
+// and a bullet on top of them, and simulates the frame in which the bullet hits.
+func clearWithOneShot(hyperspace bool) *theSimulation {
+	sim := newBareSimulation(1)
+	sim.Reset()
+	ship := *sim.At(sim.Ship(0))
+	sim.Entities = append(sim.Entities[:0], ship)
+	sim.Remaining = 0
+
+	pos := mathx.Vec2{64, 64}
+	sim.SpawnDebris(pos)
+	for i := 1; i < len(sim.Entities); i++ {
+		sim.At(i).Pos = pos
+	}
+	sim.SpawnBullet(0, pos, 0)
+
+	if hyperspace {
+		sim.Action(0, actionHyperspace, 0)
+	}
+	sim.Frame(runFrameTime)
+	return sim
+}
+
+func TestBulletGoesThroughEverythingItHits(t *testing.T) {
+	sim := clearWithOneShot(true)
+
+	// 25, then 75, 125 and 175 points for the extra kills, at a rising multiplier
+	if want := 25*1 + 75*2 + 125*3 + 175*4; sim.Score != want {
+		t.Fatalf("scored %d instead of %d", sim.Score, want)
+	}
+	for _, e := range sim.Entities {
+		if e.Mask&flagBULLET != 0 {
+			t.Fatal("the bullet is still there after the frame of its hit")
+		}
+	}
+}
+
+func TestFlawlessBonus(t *testing.T) {
+	clean, jumped := clearWithOneShot(false), clearWithOneShot(true)
+	if clean.State != stateNEXTLEVEL || jumped.State != stateNEXTLEVEL {
+		t.Fatal("the level was not cleared")
+	} else if clean.Score-jumped.Score != pointsFlawless {
+		t.Fatalf("a flawless level scored %d and a level with a jump %d", clean.Score, jumped.Score)
+	}
+}
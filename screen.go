@@ -1,46 +1,206 @@
+//go:build !headless
+// +build !headless
+
 package main
 
-import "github.com/askeladdk/pancake"
+import (
+	"github.com/askeladdk/pancake"
+	gl "github.com/askeladdk/pancake/graphics/opengl"
+)
 
 type screen interface {
 	Begin()
 	End()
 	Key(pancake.KeyEvent) error
 	Draw(pancake.DrawEvent) error
-	Frame(pancake.FrameEvent) (screen, error)
+	Frame(pancake.FrameEvent) (screenOp, error)
+}
+
+// overlay is implemented by screens that only partially cover the screen below them.
+// The screen stack draws the covered screens first.
+type overlay interface {
+	screen
+	Overlay() bool
+}
+
+type screenOpCode int
+
+const (
+	screenNOP screenOpCode = iota
+	screenPUSH
+	screenPOP
+	screenREPLACE
+	screenREPLACEALL
+)
+
+// screenOp is returned by Frame to tell the screen stack what to do next.
+// The zero value leaves the stack untouched.
+type screenOp struct {
+	Code     screenOpCode
+	Screen   screen
+	Effect   transitionEffect
+	Duration float64
 }
 
-type transitionScreen struct {
-	To screen
+// With overrides the default transition of the screen stack.
+func (op screenOp) With(effect transitionEffect, duration float64) screenOp {
+	op.Effect = effect
+	op.Duration = duration
+	return op
 }
 
-func (s transitionScreen) Begin()                                      {}
-func (s transitionScreen) End()                                        {}
-func (s transitionScreen) Key(ev pancake.KeyEvent) error               { return nil }
-func (s transitionScreen) Draw(ev pancake.DrawEvent) error             { return nil }
-func (s transitionScreen) Frame(ev pancake.FrameEvent) (screen, error) { return s.To, nil }
+// push puts a screen on top of the current one.
+func push(s screen) screenOp { return screenOp{Code: screenPUSH, Screen: s} }
 
-type screenState struct {
-	Screen screen
+// pop removes the current screen and returns to the one below it.
+func pop() screenOp { return screenOp{Code: screenPOP} }
+
+// replace swaps the current screen for another.
+func replace(s screen) screenOp { return screenOp{Code: screenREPLACE, Screen: s} }
+
+// replaceAll removes every screen and starts over with another.
+func replaceAll(s screen) screenOp { return screenOp{Code: screenREPLACEALL, Screen: s} }
+
+// screenStack runs the top screen and animates the changes between screens.
+// Effect and Duration are the transition used by operations that do not specify one.
+type screenStack struct {
+	Res        *resources
+	Screens    []screen
+	Effect     transitionEffect
+	Duration   float64
+	Transition *transition
+}
+
+func (st *screenStack) Top() screen {
+	if len(st.Screens) == 0 {
+		return nil
+	}
+	return st.Screens[len(st.Screens)-1]
 }
 
-func (gs *screenState) Do(event interface{}) error {
+func (st *screenStack) Push(s screen) {
+	st.Screens = append(st.Screens, s)
+	s.Begin()
+}
+
+func (st *screenStack) Pop() {
+	if n := len(st.Screens); n > 0 {
+		st.Screens[n-1].End()
+		st.Screens[n-1] = nil
+		st.Screens = st.Screens[:n-1]
+	}
+}
+
+func (st *screenStack) Apply(op screenOp) {
+	if op.Code == screenNOP {
+		return
+	}
+
+	effect, duration := op.Effect, op.Duration
+	if effect == effectCUT && duration == 0 {
+		effect, duration = st.Effect, st.Duration
+	}
+
+	from := st.Visible()
+
+	switch op.Code {
+	case screenPUSH:
+		st.Push(op.Screen)
+	case screenPOP:
+		st.Pop()
+	case screenREPLACE:
+		st.Pop()
+		st.Push(op.Screen)
+	case screenREPLACEALL:
+		for len(st.Screens) > 0 {
+			st.Pop()
+		}
+		st.Push(op.Screen)
+	}
+
+	if effect != effectCUT && duration > 0 {
+		st.Transition = &transition{
+			Res:      st.Res,
+			From:     from,
+			To:       st.Visible(),
+			Effect:   effect,
+			Duration: duration,
+		}
+	}
+}
+
+// Visible returns the top screen and every screen visible below it.
+func (st *screenStack) Visible() []screen {
+	i := len(st.Screens) - 1
+	for ; i > 0; i-- {
+		if o, ok := st.Screens[i].(overlay); !ok || !o.Overlay() {
+			break
+		}
+	}
+
+	visible := make([]screen, len(st.Screens)-i)
+	copy(visible, st.Screens[i:])
+	return visible
+}
+
+func (st *screenStack) Do(event interface{}) error {
+	top := st.Top()
+	if top == nil {
+		return pancake.ErrQuit
+	}
+
 	switch ev := event.(type) {
 	case pancake.QuitEvent:
 		return pancake.ErrQuit
 	case pancake.KeyEvent:
-		return gs.Screen.Key(ev)
+		// block input during transitions but let go of held keys
+		if st.Transition != nil && ev.Flags.Down() {
+			return nil
+		}
+		return top.Key(ev)
 	case pancake.FrameEvent:
-		next, err := gs.Screen.Frame(ev)
-		if next != nil {
-			gs.Screen.End()
-			next.Begin()
-			gs.Screen = next
+		st.Res.Toasts.Frame(ev.DeltaTime)
+		if st.Transition != nil {
+			if st.Transition.Frame(ev); st.Transition.Done() {
+				st.Transition = nil
+			}
+			return nil
 		}
+		op, err := top.Frame(ev)
+		st.Apply(op)
 		return err
 	case pancake.DrawEvent:
-		return gs.Screen.Draw(ev)
+		return st.Draw(ev)
 	default:
 		return nil
 	}
 }
+
+// Draw draws the top screen and every screen visible below it.
+// Only the top screen advances, so the others are drawn without interpolation.
+func (st *screenStack) Draw(ev pancake.DrawEvent) error {
+	gl.Clear(gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT)
+
+	if err := st.drawScreens(ev); err != nil {
+		return err
+	}
+
+	// notifications stay on top of everything, even transitions
+	st.Res.Shader.Begin()
+	st.Res.Toasts.Draw(st.Res.Drawer)
+	st.Res.Shader.End()
+	return nil
+}
+
+func (st *screenStack) drawScreens(ev pancake.DrawEvent) error {
+	if st.Transition != nil {
+		return st.Transition.Draw()
+	}
+
+	visible := st.Visible()
+	if err := drawScreens(visible[:len(visible)-1]); err != nil {
+		return err
+	}
+
+	return st.Top().Draw(ev)
+}
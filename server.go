@@ -0,0 +1,513 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// A game server runs the simulation without a window or sound and is the only one that does so.
+// Clients connect over TCP or UDP, send their controller state every frame
+// and receive a snapshot of the game state every tick, which they only display.
+// TCP connections carry the same packets as UDP, each preceded by its length.
+
+const (
+	serverRestartTime = 5 * time.Second // pause between a game over and the next game
+	serverQueueSize   = 64              // packets that are queued for a TCP client before it is dropped
+	maxUDPPacketSize  = 65507
+)
+
+var errSlowClient = errors.New("cannot keep up")
+
+// serverPeer is how a client is reached, over a TCP connection or at a UDP address.
+type serverPeer interface {
+	Send(data []byte) error
+	Close() error
+	String() string
+}
+
+// tcpPeer writes to its connection on a goroutine of its own, so that a client that does not
+// read its packets is dropped when its queue fills up instead of holding up the game.
+type tcpPeer struct {
+	Conn  net.Conn
+	queue chan []byte
+	done  chan struct{} // closed by Close, so that the writer stops
+	once  sync.Once
+}
+
+func newTCPPeer(conn net.Conn) *tcpPeer {
+	p := &tcpPeer{
+		Conn:  conn,
+		queue: make(chan []byte, serverQueueSize),
+		done:  make(chan struct{}),
+	}
+	go p.write()
+	return p
+}
+
+// Send queues a packet and fails if the client cannot keep up.
+func (p *tcpPeer) Send(data []byte) error {
+	select {
+	case <-p.done:
+		return net.ErrClosed
+	default:
+	}
+
+	select {
+	case p.queue <- data:
+		return nil
+	default:
+		return errSlowClient
+	}
+}
+
+// write sends the queued packets until the peer is closed.
+// The packets that are still queued then, such as a goodbye, are sent before the connection is closed.
+func (p *tcpPeer) write() {
+	defer p.Conn.Close()
+	w := bufio.NewWriter(p.Conn)
+	for {
+		select {
+		case data := <-p.queue:
+			p.Conn.SetWriteDeadline(time.Now().Add(netTimeout))
+			if err := writeFrame(w, data); err != nil {
+				return
+			} else if len(p.queue) == 0 {
+				if err := w.Flush(); err != nil {
+					return
+				}
+			}
+		case <-p.done:
+			p.Conn.SetWriteDeadline(time.Now().Add(netTimeout))
+			for len(p.queue) > 0 {
+				if err := writeFrame(w, <-p.queue); err != nil {
+					return
+				}
+			}
+			w.Flush()
+			return
+		}
+	}
+}
+
+func (p *tcpPeer) Close() error {
+	p.once.Do(func() { close(p.done) })
+	return nil
+}
+
+func (p *tcpPeer) String() string { return "tcp://" + p.Conn.RemoteAddr().String() }
+
+type udpPeer struct {
+	Conn *net.UDPConn
+	Addr *net.UDPAddr
+}
+
+func (p *udpPeer) Send(data []byte) error {
+	_, err := p.Conn.WriteToUDP(data, p.Addr)
+	return err
+}
+
+func (p *udpPeer) Close() error   { return nil }
+func (p *udpPeer) String() string { return "udp://" + p.Addr.String() }
+
+// writeFrame writes a packet to a stream, preceded by its length.
+func writeFrame(w io.Writer, data []byte) error {
+	var size [4]byte
+	binary.LittleEndian.PutUint32(size[:], uint32(len(data)))
+	if _, err := w.Write(append(size[:], data...)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// readFrame reads a packet from a stream.
+func readFrame(r io.Reader) ([]byte, error) {
+	var size [4]byte
+	if _, err := io.ReadFull(r, size[:]); err != nil {
+		return nil, err
+	}
+
+	n := binary.LittleEndian.Uint32(size[:])
+	if n > maxSnapshotSize {
+		return nil, errBadSnapshot
+	}
+	data := make([]byte, n)
+	_, err := io.ReadFull(r, data)
+	return data, err
+}
+
+// serverPacket is a packet that arrived from a client. A nil packet means that the client disconnected.
+type serverPacket struct {
+	Peer serverPeer
+	Data []byte
+}
+
+type serverClient struct {
+	Peer     serverPeer
+	Player   int
+	Input    remoteController // fed by the commands of the client
+	Ack      int              // the latest snapshot that the client has received
+	lastSeen time.Time
+}
+
+// gameServer is an authoritative game server.
+// The game starts when every player has joined and starts over some time after it is over.
+type gameServer struct {
+	Sim      *theSimulation
+	Setup    netSetup // only the seed, mode, rules and fixed-point mode are used
+	Players  int
+	Once     bool           // stop after the first game
+	Feed     *spectatorFeed // publishes the game to spectators, if enabled
+	Log      io.Writer
+	Tick     int
+	Started  bool
+	clients  map[string]*serverClient
+	listener net.Listener
+	conn     *net.UDPConn
+	history  snapshotRing
+	packets  chan serverPacket
+	done     chan struct{} // closed by Close, so that the receivers stop handing over packets
+	gameOver time.Time
+}
+
+func newGameServer(setup netSetup, players int) *gameServer {
+	return &gameServer{
+		Sim:     newBareSimulation(setup.Seed),
+		Setup:   setup,
+		Players: players,
+		Log:     io.Discard,
+		clients: make(map[string]*serverClient),
+		packets: make(chan serverPacket, 256),
+		done:    make(chan struct{}),
+	}
+}
+
+// Listen accepts clients on a TCP and a UDP port at the same address, such as ":7777".
+func (gs *gameServer) Listen(addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	uaddr, err := net.ResolveUDPAddr("udp", ln.Addr().String())
+	if err != nil {
+		ln.Close()
+		return err
+	}
+
+	conn, err := net.ListenUDP("udp", uaddr)
+	if err != nil {
+		ln.Close()
+		return err
+	}
+
+	fmt.Fprintf(gs.Log, "listening on tcp://%s and udp://%s\n", ln.Addr(), conn.LocalAddr())
+	gs.listener = ln
+	gs.conn = conn
+	go gs.acceptTCP(ln)
+	go gs.receiveUDP(conn)
+	return nil
+}
+
+// Addr returns the address that the server listens on.
+func (gs *gameServer) Addr() net.Addr {
+	return gs.listener.Addr()
+}
+
+// Close stops accepting clients and disconnects the ones there are.
+func (gs *gameServer) Close() error {
+	gs.broadcast(makePacket(packetBYE, nil))
+	for key := range gs.clients {
+		gs.drop(key, "disconnected")
+	}
+	close(gs.done)
+	gs.conn.Close()
+	return gs.listener.Close()
+}
+
+func (gs *gameServer) acceptTCP(ln net.Listener) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		go gs.receiveTCP(newTCPPeer(conn))
+	}
+}
+
+func (gs *gameServer) receiveTCP(p *tcpPeer) {
+	defer p.Close()
+	r := bufio.NewReader(p.Conn)
+	for {
+		data, err := readFrame(r)
+		if err != nil {
+			gs.hand(serverPacket{p, nil})
+			return
+		} else if !gs.hand(serverPacket{p, data}) {
+			return
+		}
+	}
+}
+
+func (gs *gameServer) receiveUDP(conn *net.UDPConn) {
+	buf := make([]byte, netMaxPacketSize)
+	for {
+		n, from, err := conn.ReadFromUDP(buf)
+		if err != nil || !gs.hand(serverPacket{&udpPeer{conn, from}, append([]byte(nil), buf[:n]...)}) {
+			return
+		}
+	}
+}
+
+// hand passes a packet on to the game loop.
+// It reports false once the server is closed and nobody handles packets anymore.
+func (gs *gameServer) hand(p serverPacket) bool {
+	select {
+	case gs.packets <- p:
+		return true
+	case <-gs.done:
+		return false
+	}
+}
+
+// Run simulates the game at a fixed rate until it is over, if it only plays once.
+func (gs *gameServer) Run() error {
+	ticker := time.NewTicker(time.Second / netTickRate)
+	defer ticker.Stop()
+	for range ticker.C {
+		if done := gs.Frame(time.Now()); done {
+			return nil
+		}
+	}
+	return nil
+}
+
+// Frame handles the packets of the clients, advances the game by one tick and sends the snapshot.
+// It reports whether the server is done.
+func (gs *gameServer) Frame(now time.Time) bool {
+	gs.receive(now)
+
+	switch {
+	case !gs.Started && len(gs.clients) >= gs.Players:
+		gs.newGame()
+	case gs.Started && netGameOver(gs.Sim) && gs.gameOver.IsZero():
+		fmt.Fprintf(gs.Log, "game over with a score of %d at level %d\n", gs.Sim.Score, 1+gs.Sim.Level)
+		gs.gameOver = now
+	case gs.Started && !gs.gameOver.IsZero() && now.Sub(gs.gameOver) >= serverRestartTime:
+		if gs.Once {
+			return true
+		}
+		gs.Setup.Seed = now.UnixNano()
+		gs.newGame()
+	case gs.Started && gs.gameOver.IsZero():
+		states := make([]controllerState, len(gs.Sim.Players))
+		for _, c := range gs.clients {
+			states[c.Player] = c.Input.Poll()
+		}
+		netAdvance(gs.Sim, states)
+	}
+
+	if gs.Feed != nil {
+		gs.Feed.Publish(gs.Sim)
+	}
+
+	gs.sendSnapshots()
+	gs.Tick++
+	return false
+}
+
+func (gs *gameServer) newGame() {
+	fmt.Fprintf(gs.Log, "new game with seed %d\n", gs.Setup.Seed)
+	gs.Sim.Fixed = gs.Setup.Fixed
+	if gs.Setup.Mode == modeVERSUS {
+		gs.Sim.NewMatch(gs.Setup.Seed, gs.Players, gs.Setup.Rules)
+	} else {
+		gs.Sim.NewGame(gs.Setup.Seed, gs.Players)
+	}
+	gs.Sim.Reset()
+	gs.Started = true
+	gs.gameOver = time.Time{}
+}
+
+func (gs *gameServer) receive(now time.Time) {
+	for {
+		select {
+		case p := <-gs.packets:
+			gs.handle(now, p)
+		default:
+			gs.dropQuiet(now)
+			return
+		}
+	}
+}
+
+func (gs *gameServer) handle(now time.Time, p serverPacket) {
+	key := p.Peer.String()
+	c := gs.clients[key]
+	if p.Data == nil {
+		gs.drop(key, "disconnected")
+		return
+	}
+
+	kind, r, ok := parsePacket(p.Data)
+	if !ok {
+		return
+	} else if c != nil {
+		c.lastSeen = now
+	}
+
+	switch {
+	case kind == packetJOIN && c == nil:
+		player, ok := gs.freePlayer()
+		if !ok {
+			p.Peer.Send(makePacket(packetBYE, nil))
+			p.Peer.Close()
+			return
+		}
+		c = &serverClient{Peer: p.Peer, Player: player, Ack: -1, lastSeen: now}
+		gs.clients[key] = c
+		fmt.Fprintf(gs.Log, "%s joined as player %d\n", key, 1+player)
+		fallthrough
+	case kind == packetJOIN:
+		// the answer may have been lost
+		c.Peer.Send(makePacket(packetACCEPT, func(w *saveWriter) {
+			w.u32(uint32(c.Player))
+		}))
+	case kind == packetCOMMAND && c != nil:
+		ack := int(r.i64())
+		cs := readInput(r)
+		if r.err == nil {
+			if ack > c.Ack && ack <= gs.Tick {
+				c.Ack = ack
+			}
+			c.Input.Feed(cs)
+		}
+	case kind == packetBYE:
+		gs.drop(key, "left")
+	}
+}
+
+// freePlayer returns the first player that is not taken by a client.
+func (gs *gameServer) freePlayer() (int, bool) {
+	for p := 0; p < gs.Players; p++ {
+		taken := false
+		for _, c := range gs.clients {
+			taken = taken || c.Player == p
+		}
+		if !taken {
+			return p, true
+		}
+	}
+	return 0, false
+}
+
+func (gs *gameServer) drop(key, reason string) {
+	if c, ok := gs.clients[key]; ok {
+		fmt.Fprintf(gs.Log, "%s %s\n", key, reason)
+		c.Peer.Close()
+		delete(gs.clients, key)
+	}
+}
+
+// dropQuiet forgets the clients that have not been heard from for a while, which matters for UDP.
+func (gs *gameServer) dropQuiet(now time.Time) {
+	for key, c := range gs.clients {
+		if now.Sub(c.lastSeen) > netTimeout {
+			gs.drop(key, "timed out")
+		}
+	}
+}
+
+// sendSnapshots sends the game state to every client,
+// as a difference with the last snapshot that the client has received.
+func (gs *gameServer) sendSnapshots() {
+	state, _ := gs.Sim.MarshalBinary()
+	gs.history.Put(gs.Tick, state)
+
+	missing := 0
+	if !gs.Started {
+		missing = gs.Players - len(gs.clients)
+	}
+
+	for key, c := range gs.clients {
+		base, ok := gs.history.Get(c.Ack)
+		if !ok {
+			c.Ack = -1
+		}
+
+		msg := snapshotMessage{
+			Tick:    gs.Tick,
+			Base:    c.Ack,
+			Missing: missing,
+			State:   state,
+			Events:  gs.Sim.Events,
+		}
+		data := makePacket(packetSNAPSHOT, func(w *saveWriter) {
+			msg.write(w, base)
+		})
+
+		if len(data) > maxUDPPacketSize {
+			if _, udp := c.Peer.(*udpPeer); udp {
+				continue
+			}
+		}
+
+		if err := c.Peer.Send(data); err != nil {
+			gs.drop(key, err.Error())
+		}
+	}
+}
+
+func (gs *gameServer) broadcast(data []byte) {
+	for _, c := range gs.clients {
+		c.Peer.Send(data)
+	}
+}
+
+// serverCommand runs a game server from the command line.
+func serverCommand(args []string, log io.Writer) error {
+	fs := flag.NewFlagSet("server", flag.ContinueOnError)
+	listen := fs.String("listen", ":7777", "address to accept clients on, over both TCP and UDP")
+	players := fs.Int("players", 1, "number of players, the game starts when all of them have joined")
+	versus := fs.Bool("versus", false, "play a versus match instead of a co-op game")
+	seed := fs.Int64("seed", time.Now().UnixNano(), "seed of the first game")
+	once := fs.Bool("once", false, "stop after the first game")
+	publish := fs.String("publish", "", "publish the game to spectators on a TCP address such as :7778")
+	fixedPoint := fs.Bool("fixed", false, "simulate with fixed-point math")
+	if err := fs.Parse(args); err != nil {
+		return err
+	} else if *players < 1 || *players > maxPlayers {
+		return fmt.Errorf("the number of players must be between 1 and %d", maxPlayers)
+	} else if *versus && *players < 2 {
+		return fmt.Errorf("a versus match needs at least 2 players")
+	}
+
+	setup := netSetup{Seed: *seed, Mode: modeCOOP, Fixed: *fixedPoint}
+	if *versus {
+		setup.Mode = modeVERSUS
+		setup.Rules = defaultVersusRules()
+	}
+
+	gs := newGameServer(setup, *players)
+	gs.Once = *once
+	gs.Log = log
+	if *publish != "" {
+		feed, err := listenFeed(*publish)
+		if err != nil {
+			return err
+		}
+		defer feed.Close()
+		gs.Feed = feed
+	}
+	if err := gs.Listen(*listen); err != nil {
+		return err
+	}
+	defer gs.Close()
+	return gs.Run()
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"net"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestClosedServerStopsReceiving(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	gs := newGameServer(netSetup{Seed: 1}, 1)
+	if err := gs.Listen("127.0.0.1:0"); err != nil {
+		t.Fatal(err)
+	}
+	addr := gs.Addr().String()
+	tcp, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	udp, err := net.Dial("udp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// flood the server while nobody handles the packets
+	packet := makePacket(packetINPUT, nil)
+	for i := 0; i < 2*cap(gs.packets); i++ {
+		writeFrame(tcp, packet)
+		udp.Write(packet)
+	}
+	for i := 0; i < 1000 && len(gs.packets) < cap(gs.packets); i++ {
+		time.Sleep(time.Millisecond)
+	}
+	tcp.Close()
+	udp.Close()
+	gs.Close()
+
+	for i := 0; i < 1000 && runtime.NumGoroutine() > before; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	if n := runtime.NumGoroutine(); n > before {
+		t.Fatalf("%d goroutines are still running after the server was closed", n-before)
+	}
+}
+
+func TestServerDropsClientThatDoesNotRead(t *testing.T) {
+	gs := newGameServer(netSetup{Seed: 1}, 1)
+	if err := gs.Listen("127.0.0.1:0"); err != nil {
+		t.Fatal(err)
+	}
+	defer gs.Close()
+
+	conn, err := net.Dial("tcp", gs.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.(*net.TCPConn).SetReadBuffer(1024)
+	writeFrame(conn, makePacket(packetJOIN, nil))
+
+	joined := false
+	for i := 0; i < 100000; i++ {
+		start := time.Now()
+		gs.Frame(start)
+		if took := time.Since(start); took > time.Second {
+			t.Fatalf("a frame took %v while a client did not read", took)
+		}
+
+		if len(gs.clients) > 0 {
+			joined = true
+		} else if joined {
+			return
+		} else {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	t.Fatalf("the client was not dropped (joined: %v)", joined)
+}
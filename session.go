@@ -0,0 +1,87 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"time"
+)
+
+var errOtherGame = errors.New("the save is of a game with other players or another mode")
+
+// session is one game instance: the simulation,
+// the controllers of the players and everything that observes the run.
+type session struct {
+	Sim          *theSimulation
+	Controllers  []controller // one per player that can join
+	Stats        runStats
+	Achievements achievementTracker
+	RunLog       *runRecorder // records the run, if it can be played again
+	Rewound      bool         // time was rewound, so the run does not count for high scores and achievements
+}
+
+func newSession(sim *theSimulation, ctrls []controller, achievements *achievementStore) *session {
+	return &session{
+		Sim:          sim,
+		Controllers:  ctrls,
+		Achievements: achievementTracker{Store: achievements},
+	}
+}
+
+// Active returns the controllers of the players in the game.
+func (s *session) Active() []controller {
+	if n := len(s.Sim.Players); n < len(s.Controllers) {
+		return s.Controllers[:n]
+	}
+	return s.Controllers
+}
+
+// NewMatch starts a versus match between all players.
+func (s *session) NewMatch(seed int64, players int, rules versusRules) {
+	s.NewGame(seed, players)
+	s.Sim.NewMatch(seed, len(s.Sim.Players), rules)
+	s.RunLog = nil
+}
+
+// ResetControllers releases the buttons of every controller.
+func (s *session) ResetControllers() {
+	for _, c := range s.Controllers {
+		c.Reset()
+	}
+}
+
+// NewGame starts a new run from the first level.
+func (s *session) NewGame(seed int64, players int) {
+	if players > len(s.Controllers) {
+		players = len(s.Controllers)
+	}
+	s.Sim.NewGame(seed, players)
+	s.Stats = runStats{Seed: seed, Date: time.Now()}
+	s.Achievements.NewGame()
+	s.Rewound = false
+	s.RunLog = newRunRecorder(seed, len(s.Sim.Players), s.Sim.Fixed)
+}
+
+// LoadFile restores a game that was saved during this session, see Load.
+func (s *session) LoadFile(filename string) error {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	return s.Load(data)
+}
+
+// Load restores a saved game with the same players and mode as the one being played.
+// The statistics and achievement counters start over, so that they do not mix the two games.
+func (s *session) Load(data []byte) error {
+	saved := newBareSimulation(0)
+	if err := saved.UnmarshalBinary(data); err != nil {
+		return err
+	} else if len(saved.Players) != len(s.Sim.Players) || saved.Mode != s.Sim.Mode {
+		return errOtherGame
+	} else if err := s.Sim.UnmarshalBinary(data); err != nil {
+		return err
+	}
+	s.Stats = runStats{Seed: s.Stats.Seed, Date: time.Now()}
+	s.Achievements.NewGame()
+	return nil
+}
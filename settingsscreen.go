@@ -0,0 +1,122 @@
+//go:build !headless
+// +build !headless
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/askeladdk/pancake"
+	"github.com/askeladdk/pancake/graphics"
+	"github.com/askeladdk/pancake/input"
+	"github.com/askeladdk/pancake/text"
+	"github.com/faiface/beep"
+	"github.com/faiface/beep/speaker"
+)
+
+const (
+	settingsMusic = iota
+	settingsSounds
+	settingsRewind
+	settingsBindings
+	settingsBindings2
+	settingsBack
+)
+
+type settingsScreen struct {
+	Res        *resources
+	Sim        *theSimulation
+	Music      *beep.Ctrl
+	Text       *text.Text
+	Drawer     *tintDrawer
+	Shader     *graphics.ShaderProgram
+	Background staticImage
+	Menu       menu
+	Done       bool
+}
+
+func newSettingsScreen(res *resources, sim *theSimulation) *settingsScreen {
+	return &settingsScreen{
+		Res:        res,
+		Sim:        sim,
+		Music:      res.Music,
+		Text:       res.newMenuText(),
+		Drawer:     res.Drawer,
+		Shader:     res.Shader,
+		Background: res.Background,
+	}
+}
+
+func (s *settingsScreen) Begin() {
+	s.Done = false
+	s.Menu.Reset()
+	s.print()
+}
+
+func (s *settingsScreen) End() {}
+
+func (s *settingsScreen) Key(ev pancake.KeyEvent) error {
+	if ev.Key == input.KeyEscape {
+		s.Done = s.Done || ev.Flags.Pressed()
+		return nil
+	}
+	s.Menu.Key(ev)
+	s.print()
+	return nil
+}
+
+func (s *settingsScreen) Frame(ev pancake.FrameEvent) (screenOp, error) {
+	if choice, chosen := s.Menu.Choice(); chosen {
+		switch choice {
+		case settingsMusic:
+			speaker.Lock()
+			s.Music.Paused = !s.Music.Paused
+			speaker.Unlock()
+		case settingsSounds:
+			s.Sim.Mute = !s.Sim.Mute
+		case settingsRewind:
+			s.Res.Rewind = !s.Res.Rewind
+		case settingsBindings:
+			return push(newBindingsScreen(s.Res, 0)), nil
+		case settingsBindings2:
+			return push(newBindingsScreen(s.Res, 1)), nil
+		case settingsBack:
+			s.Done = true
+		}
+		s.print()
+	}
+
+	if s.Done {
+		return pop(), nil
+	}
+	return screenOp{}, nil
+}
+
+func (s *settingsScreen) Draw(ev pancake.DrawEvent) error {
+	s.Shader.Begin()
+	s.Drawer.Draw(s.Background)
+	s.Drawer.Draw(s.Text)
+	s.Shader.End()
+	return nil
+}
+
+func (s *settingsScreen) print() {
+	s.Menu.Items = []string{
+		settingsMusic:     "Music: " + onOff(!s.Music.Paused),
+		settingsSounds:    "Sound effects: " + onOff(!s.Sim.Mute),
+		settingsRewind:    "Rewind time: " + onOff(s.Res.Rewind),
+		settingsBindings:  "Key bindings",
+		settingsBindings2: "Player 2 key bindings",
+		settingsBack:      "Back",
+	}
+	s.Text.Clear()
+	fmt.Fprintf(s.Text, "Settings\n\n")
+	s.Menu.Print(s.Text)
+}
+
+func onOff(on bool) string {
+	if on {
+		return "on"
+	}
+	return "off"
+}
@@ -0,0 +1,71 @@
+//go:build !headless
+// +build !headless
+
+package main
+
+import (
+	"image/color"
+
+	"github.com/askeladdk/pancake/graphics"
+	"github.com/askeladdk/pancake/mathx"
+	"github.com/faiface/beep"
+	"github.com/faiface/beep/speaker"
+)
+
+// simMedia is how the simulation looks and sounds.
+type simMedia struct {
+	ImageAtlas *graphics.Texture
+	Images     []graphics.Image
+	Sounds     []*beep.Buffer
+	Reversed   []*beep.Buffer // the sounds played backwards
+}
+
+func (s *theSimulation) PlaySound(i int) {
+	if s.Mute || s.Silent {
+		return
+	}
+	snd := s.Sounds[i]
+	speaker.Play(snd.Streamer(0, snd.Len()))
+}
+
+// PlaySoundReversed plays a sound backwards, when time is rewound.
+func (s *theSimulation) PlaySoundReversed(i int) {
+	if s.Mute || s.Silent {
+		return
+	}
+	snd := s.Reversed[i]
+	speaker.Play(snd.Streamer(0, snd.Len()))
+}
+
+func (s *theSimulation) TintColorAt(i int) color.Color {
+	if e := s.At(i); len(s.Players) > 1 && e.Mask&(flagSPACESHIP|flagBULLET) != 0 {
+		return playerColors[e.Player%maxPlayers]
+	}
+	return color.RGBA{0xff, 0xff, 0xff, 0xff}
+}
+
+func (s *theSimulation) TextureAt(_ int) *graphics.Texture {
+	return s.ImageAtlas
+}
+
+func (s *theSimulation) TextureRegionAt(i int) graphics.TextureRegion {
+	return s.Images[s.Entities[i].ImageID].TextureRegion()
+}
+
+func (s *theSimulation) ModelViewAt(i int) mathx.Aff3 {
+	e := s.At(i)
+	pos := e.Pos0.Lerp(e.Pos, s.Alpha)
+	rot := mathx.Lerp(e.Rot0, e.Rot, s.Alpha)
+	return mathx.
+		ScaleAff3(s.Images[e.ImageID].Scale()).
+		Rotated(rot).
+		Translated(pos)
+}
+
+func (s *theSimulation) OriginAt(i int) mathx.Vec2 {
+	return mathx.Vec2{}
+}
+
+func (s *theSimulation) ZOrderAt(i int) float64 {
+	return 0
+}
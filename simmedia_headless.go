@@ -0,0 +1,9 @@
+//go:build headless
+// +build headless
+
+package main
+
+// simMedia is empty because a headless build can neither be seen nor heard.
+type simMedia struct{}
+
+func (s *theSimulation) PlaySound(i int) {}
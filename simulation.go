@@ -1,13 +1,10 @@
 package main
 
 import (
+	"image"
 	"image/color"
-	"math/rand"
 
-	"github.com/askeladdk/pancake/graphics"
 	"github.com/askeladdk/pancake/mathx"
-	"github.com/faiface/beep"
-	"github.com/faiface/beep/speaker"
 )
 
 type gameState int
@@ -16,6 +13,7 @@ const (
 	statePLAYING gameState = iota
 	stateNEXTLEVEL
 	stateGAMEOVER
+	stateROUNDOVER
 )
 
 const (
@@ -37,7 +35,24 @@ const (
 	imageDebris3
 )
 
-const shipID = 0
+// imageRects are the regions of the images on the sprite sheet.
+// The simulation needs their sizes to wrap the entities around the screen.
+var imageRects = [...]image.Rectangle{
+	imageShip:     image.Rect(0, 0, 32, 32),
+	imageAsteroid: image.Rect(64, 192, 128, 256),
+	imageBullet:   image.Rect(112, 64, 128, 80),
+	imageDebris0:  image.Rect(128, 192, 160, 224),
+	imageDebris1:  image.Rect(160, 192, 192, 224),
+	imageDebris2:  image.Rect(128, 224, 160, 256),
+	imageDebris3:  image.Rect(160, 224, 192, 256),
+}
+
+// screenSize is the resolution of the game, which is also the size of the playing field.
+var screenSize = image.Point{640, 360}
+
+func imageSize(id int) mathx.Vec2 {
+	return mathx.FromPoint(imageRects[id].Size())
+}
 
 type actionCode int
 
@@ -45,6 +60,7 @@ const (
 	actionForward actionCode = iota
 	actionTurn
 	actionFire
+	actionHyperspace
 )
 
 type action struct {
@@ -70,20 +86,88 @@ type entity struct {
 	Lifetime float64    // time until death in seconds, for EPHEMERAL
 	Pos0     mathx.Vec2 // last position, for interpolation
 	Rot0     float64    // last rotation, for interpolation
+	Kills    int        // number of kills, for BULLET
+	Player   int        // owning player, for SPACESHIP and BULLET
+}
+
+type eventCode int
+
+const (
+	eventSCORE      eventCode = iota // Value is the change in score
+	eventFIRE                        // a bullet was fired
+	eventHIT                         // a bullet hit its first target
+	eventDESTROYED                   // Value is the mask of what was destroyed
+	eventBOUNCE                      // two rocks bounced off each other
+	eventHYPERSPACE                  // the ship jumped through hyperspace
+	eventDEATH                       // Value is the mask of what killed the ship
+	eventTHRUST                      // the ship fired its engine
+	numEventCodes
+)
+
+// eventSounds are the sounds that events make, for when the simulation could not play them itself.
+var eventSounds = map[eventCode]int{
+	eventFIRE:      0,
+	eventDESTROYED: 1,
+	eventDEATH:     1,
+	eventBOUNCE:    2,
+}
+
+// simEvent is something noteworthy that happened during the last frame.
+type simEvent struct {
+	Code  eventCode
+	Pos   mathx.Vec2
+	Value int
 }
 
+const (
+	maxPlayers  = 2   // on one keyboard or network, versus itself works for any number
+	coopLives   = 3   // ships per player when playing together
+	respawnTime = 2.0 // seconds until a lost ship returns
+	respawnArea = 96  // radius that must be free of rocks to respawn
+)
+
+// playerColors tell the ships and bullets of the players apart.
+var playerColors = [maxPlayers]color.RGBA{
+	{0x80, 0xc0, 0xff, 0xff},
+	{0xff, 0xb0, 0x60, 0xff},
+}
+
+type player struct {
+	Score   int
+	Lives   int     // ships left, including the one in play
+	Respawn float64 // time until the next ship arrives, while it has none
+	Wins    int     // rounds won in versus mode
+}
+
+const bulletLifetime = 0.6
+
+const debrisSpread = 16 // distance from the destroyed rock at which debris appears
+
 type theSimulation struct {
-	ImageAtlas *graphics.Texture
-	Images     []graphics.Image
-	Sounds     []*beep.Buffer
-	Bounds     mathx.Rectangle
-	Entities   []entity
-	Actions    []action
-	Alpha      float64
-	State      gameState
-	Level      int
-	Score      int
-	Remaining  int
+	simMedia
+	Bounds      mathx.Rectangle
+	Entities    []entity
+	Actions     []action
+	Alpha       float64
+	State       gameState
+	Level       int
+	Score       int // total score of all players
+	Players     []player
+	Mode        gameMode
+	Rules       versusRules
+	RoundWinner int // player that won the last versus round, or -1 for a draw
+	Remaining   int
+	Multiplier  int
+	ComboTime   float64
+	Damaged     bool // a ship was lost or jumped through hyperspace, so the level is not flawless
+	Events      []simEvent
+	Seed        int64
+	Rand        random
+	Mute        bool
+	Silent      bool   // set while frames that were already heard are simulated again
+	Hashing     bool   // compute TickHash after every frame
+	TickHash    uint64 // StateHash after the last frame, while Hashing is set
+	Fixed       bool   // simulate with fixed-point math, see fixedpoint.go
 }
 
 var asteroidsPerLevel = []int{
@@ -99,17 +183,52 @@ var asteroidsPerLevel = []int{
 	89,
 }
 
-func (s *theSimulation) PlaySound(i int) {
-	snd := s.Sounds[i]
-	speaker.Play(snd.Streamer(0, snd.Len()))
+// NewGame starts over from the first level with the given number of players.
+// The seed makes the game reproducible.
+func (s *theSimulation) NewGame(seed int64, players int) {
+	lives := 1
+	if players > 1 {
+		lives = coopLives
+	}
+
+	s.Level = 0
+	s.Score = 0
+	s.Mode = modeCOOP
+	s.Rules = versusRules{}
+	s.Players = s.Players[:0]
+	for i := 0; i < players; i++ {
+		s.Players = append(s.Players, player{Lives: lives})
+	}
+	s.Seed = seed
+	s.Rand.Seed(seed)
 }
 
 func (s *theSimulation) Reset() {
 	s.State = statePLAYING
 	s.Remaining = 0
+	s.Multiplier = 1
+	s.ComboTime = 0
+	s.Damaged = false
+	s.Events = s.Events[:0]
 	s.Entities = s.Entities[:0]
-	s.SpawnSpaceship()
-	for i := 0; i < asteroidsPerLevel[s.Level%len(asteroidsPerLevel)]; i++ {
+	if s.Mode == modeVERSUS {
+		s.beginRound()
+	}
+	for i := range s.Players {
+		s.Players[i].Respawn = 0
+		if s.Players[i].Lives > 0 {
+			s.SpawnSpaceship(i)
+		}
+	}
+	s.spawnAsteroids()
+}
+
+func (s *theSimulation) spawnAsteroids() {
+	n := asteroidsPerLevel[s.Level%len(asteroidsPerLevel)]
+	if s.Mode == modeVERSUS {
+		n = s.Rules.Asteroids
+	}
+	for i := 0; i < n; i++ {
 		s.SpawnAsteroid()
 	}
 }
@@ -118,34 +237,19 @@ func (s *theSimulation) Len() int {
 	return len(s.Entities)
 }
 
-func (s *theSimulation) TintColorAt(i int) color.Color {
-	return color.RGBA{0xff, 0xff, 0xff, 0xff}
-}
-
-func (s *theSimulation) TextureAt(_ int) *graphics.Texture {
-	return s.ImageAtlas
-}
-
-func (s *theSimulation) TextureRegionAt(i int) graphics.TextureRegion {
-	return s.Images[s.Entities[i].ImageID].TextureRegion()
-}
-
-func (s *theSimulation) ModelViewAt(i int) mathx.Aff3 {
-	e := s.At(i)
-	pos := e.Pos0.Lerp(e.Pos, s.Alpha)
-	rot := mathx.Lerp(e.Rot0, e.Rot, s.Alpha)
-	return mathx.
-		ScaleAff3(s.Images[e.ImageID].Scale()).
-		Rotated(rot).
-		Translated(pos)
-}
-
-func (s *theSimulation) OriginAt(i int) mathx.Vec2 {
-	return mathx.Vec2{}
+// newBareSimulation creates a simulation that can neither be seen nor heard,
+// for servers and tools that run without a window.
+func newBareSimulation(seed int64) *theSimulation {
+	s := &theSimulation{
+		Bounds: mathx.Rectangle{Max: mathx.FromPoint(screenSize)},
+		Mute:   true,
+	}
+	s.NewGame(seed, 1)
+	return s
 }
 
-func (s *theSimulation) ZOrderAt(i int) float64 {
-	return 0
+func (s *theSimulation) emit(code eventCode, pos mathx.Vec2, value int) {
+	s.Events = append(s.Events, simEvent{code, pos, value})
 }
 
 func (s *theSimulation) Action(entityID int, code actionCode, value float64) {
@@ -153,45 +257,143 @@ func (s *theSimulation) Action(entityID int, code actionCode, value float64) {
 }
 
 func (s *theSimulation) collisionResponse(a, b *entity) {
+	// ships can be anywhere in the list, so make sure a ship comes first
+	if b.Mask&flagSPACESHIP != 0 {
+		a, b = b, a
+	}
+
 	if a.Mask&(flagASTEROID|flagDEBRIS) != 0 && b.Mask&(flagASTEROID|flagDEBRIS) != 0 {
-		v := a.Pos.Sub(b.Pos).Unit()
-		a.Vel = v.Mul(a.MaxV * .5)
-		b.Vel = v.Mul(b.MaxV * .5).Neg()
-		a.RotV += mathx.Tau / 64 * (1 + 2*rand.Float64())
-		b.RotV += mathx.Tau / 64 * (1 + 2*rand.Float64())
+		v := s.unit(a.Pos.Sub(b.Pos))
+		a.Vel = s.scale(v, a.MaxV*.5)
+		b.Vel = s.scale(v, b.MaxV*.5).Neg()
+		a.RotV += s.mul(mathx.Tau/64, 1+2*s.random())
+		b.RotV += s.mul(mathx.Tau/64, 1+2*s.random())
+		s.emit(eventBOUNCE, a.Pos.Lerp(b.Pos, 0.5), 0)
 		s.PlaySound(2)
 	} else if (a.Mask|b.Mask)&(flagASTEROID|flagBULLET) == (flagASTEROID | flagBULLET) {
-		a.Mask |= flagDELETED
-		b.Mask |= flagDELETED
-		s.Score += 100
-		s.Remaining--
 		if a.Mask&flagASTEROID != 0 {
-			s.SpawnDebris(a.Pos)
-		} else {
-			s.SpawnDebris(b.Pos)
+			a, b = b, a
 		}
+		b.Mask |= flagDELETED
+		s.Remaining--
+		s.kill(a, b, pointsAsteroid)
+		s.SpawnDebris(b.Pos)
 		s.PlaySound(1)
 	} else if (a.Mask|b.Mask)&(flagDEBRIS|flagBULLET) == (flagDEBRIS | flagBULLET) {
-		a.Mask |= flagDELETED
+		if a.Mask&flagDEBRIS != 0 {
+			a, b = b, a
+		}
 		b.Mask |= flagDELETED
-		s.Score += 25
 		s.Remaining--
+		s.kill(a, b, pointsDebris)
 		s.PlaySound(1)
 	} else if a.Mask&flagSPACESHIP != 0 && b.Mask&(flagASTEROID|flagDEBRIS) != 0 {
 		a.Mask |= flagDELETED
-		s.State = stateGAMEOVER
+		s.emit(eventDEATH, a.Pos, int(b.Mask&(flagASTEROID|flagDEBRIS)))
+		s.Damaged = true
+		s.loseShip(a.Player)
+		s.PlaySound(1)
+	} else if a.Mask&flagSPACESHIP != 0 && b.Mask&flagBULLET != 0 && s.canHit(b, a) {
+		a.Mask |= flagDELETED
+		b.Mask |= flagDELETED
+		s.emit(eventDEATH, a.Pos, flagBULLET)
+		if b.Player != a.Player {
+			s.addScore(b.Player, pointsShipKill, a.Pos)
+		}
+		s.loseShip(a.Player)
 		s.PlaySound(1)
 	}
 }
 
+// loseShip takes a life from a player whose ship was destroyed.
+// The game is over when nobody has any ships left. A player who is already out loses nothing.
+func (s *theSimulation) loseShip(p int) {
+	pl := &s.Players[p]
+	if pl.Lives <= 0 {
+		return
+	} else if pl.Lives--; pl.Lives > 0 {
+		pl.Respawn = respawnTime
+		return
+	} else if s.Mode == modeVERSUS {
+		s.checkRound()
+		return
+	}
+
+	for _, pl := range s.Players {
+		if pl.Lives > 0 {
+			return
+		}
+	}
+	s.State = stateGAMEOVER
+}
+
+// processRespawns brings back the ships of players that have lives left
+// once their timer has run out and their starting point is clear of rocks.
+func (s *theSimulation) processRespawns(deltaTime float64) {
+	for i := range s.Players {
+		pl := &s.Players[i]
+		if pl.Lives <= 0 || s.Ship(i) >= 0 {
+			continue
+		} else if pl.Respawn -= deltaTime; pl.Respawn > 0 {
+			continue
+		}
+
+		pos, _ := s.spawnPoint(i)
+		area := mathx.Circle{Center: pos, Radius: respawnArea}
+		clear := true
+		for _, e := range s.Entities {
+			c := mathx.Circle{Center: e.Pos, Radius: e.Radius}
+			if e.Mask&(flagASTEROID|flagDEBRIS) != 0 && s.overlaps(area, c) {
+				clear = false
+				break
+			}
+		}
+
+		if clear {
+			s.SpawnSpaceship(i)
+		}
+	}
+}
+
+// nearShip reports whether any ship is within a distance of a point.
+func (s *theSimulation) nearShip(pos mathx.Vec2, dist float64) bool {
+	area := mathx.Circle{Center: pos, Radius: dist}
+	for _, e := range s.Entities {
+		if e.Mask&flagSPACESHIP != 0 && s.overlaps(area, mathx.Circle{Center: e.Pos, Radius: e.Radius}) {
+			return true
+		}
+	}
+	return false
+}
+
+// Ship returns the entity index of the ship of a player, or -1 if it has none.
+func (s *theSimulation) Ship(p int) int {
+	for i, e := range s.Entities {
+		if e.Mask&flagSPACESHIP != 0 && e.Player == p {
+			return i
+		}
+	}
+	return -1
+}
+
+// processCollisions lets every pair of overlapping entities collide once.
+// An entity that was destroyed earlier in the frame does not collide again,
+// and debris that was spawned in the frame only collides from the next frame on.
 func (s *theSimulation) processCollisions() {
-	for i := 0; i < len(s.Entities); i++ {
+	n := len(s.Entities)
+	for i := 0; i < n; i++ {
 		a := s.At(i)
-		for j := i + 1; j < len(s.Entities); j++ {
+		if a.Mask&flagDELETED != 0 {
+			continue
+		}
+		for j := i + 1; j < n; j++ {
 			b := s.At(j)
+			if b.Mask&flagDELETED != 0 {
+				continue
+			}
 			c0 := mathx.Circle{Center: a.Pos, Radius: a.Radius}
 			c1 := mathx.Circle{Center: b.Pos, Radius: b.Radius}
-			if c0.IntersectsCircle(c1) {
+			if s.overlaps(c0, c1) {
 				s.collisionResponse(a, b)
 			}
 
@@ -202,10 +404,14 @@ func (s *theSimulation) processCollisions() {
 	}
 }
 
+// processEphemeral removes the entities whose time is up.
+// A bullet goes through everything it touches in the frame of its first hit and is removed after it.
 func (s *theSimulation) processEphemeral(deltaTime float64) {
 	for i := range s.Entities {
 		e := s.At(i)
-		if e.Mask&flagEPHEMERAL != 0 {
+		if e.Mask&flagBULLET != 0 && e.Kills > 0 {
+			e.Mask |= flagDELETED
+		} else if e.Mask&flagEPHEMERAL != 0 {
 			e.Lifetime -= deltaTime
 			if e.Lifetime <= 0 {
 				e.Mask |= flagDELETED
@@ -233,21 +439,30 @@ func (s *theSimulation) processActions(dt float64) {
 		e := s.At(a.EntityID)
 		switch a.Code {
 		case actionForward:
-			acc := mathx.FromHeading(e.Rot).Mul(a.Value * e.Thrust * dt)
+			acc := s.scale(s.heading(e.Rot), s.mul(s.mul(a.Value, e.Thrust), dt))
 			vel := e.Vel.Add(acc)
-			if vel.Len() > e.MaxV {
-				vel = vel.Unit().Mul(e.MaxV)
+			if s.length(vel) > e.MaxV {
+				vel = s.scale(s.unit(vel), e.MaxV)
 			}
 			e.Vel = vel
+			s.emit(eventTHRUST, e.Pos, 0)
 		case actionTurn:
-			e.RotV = e.Turn * a.Value * dt
+			e.RotV = s.mul(s.mul(e.Turn, a.Value), dt)
 		case actionFire:
-			s.SpawnBullet(e.Pos, e.Rot)
+			s.SpawnBullet(e.Player, e.Pos, e.Rot)
+			s.emit(eventFIRE, e.Pos, 0)
 			s.PlaySound(0)
-			s.Score -= 5
-			if s.Score < 0 {
-				s.Score = 0
-			}
+			s.addScore(e.Player, pointsShot, e.Pos)
+		case actionHyperspace:
+			size := s.Bounds.Max.Sub(s.Bounds.Min)
+			e.Pos = s.Bounds.Min.Add(mathx.Vec2{
+				s.mul(size[0], s.random()),
+				s.mul(size[1], s.random()),
+			})
+			e.Pos0 = e.Pos
+			e.Vel = mathx.Vec2{}
+			s.Damaged = true
+			s.emit(eventHYPERSPACE, e.Pos, 0)
 		}
 	}
 	s.Actions = s.Actions[:0]
@@ -258,30 +473,45 @@ func (s *theSimulation) processPhysics(deltaTime float64) {
 		e.Rot0 = e.Rot
 		e.Pos0 = e.Pos
 
-		e.Pos = e.Pos.Add(e.Vel.Mul(deltaTime))
+		e.Pos = e.Pos.Add(s.scale(e.Vel, deltaTime))
 
-		b := s.Bounds.Expand(s.Images[e.ImageID].Scale().Mul(0.5))
+		b := s.Bounds.Expand(imageSize(e.ImageID).Mul(0.5))
 		if !e.Pos.IntersectsRectangle(b) {
-			e.Pos = e.Pos.Wrap(b)
+			e.Pos = s.wrap(e.Pos, b)
 			e.Pos0 = e.Pos
 		}
 
-		e.Vel = e.Vel.Mul(e.Acc)
-		e.RotV = mathx.Clamp(e.RotV*e.RotA, -e.MinRotV, e.MinRotV)
-		e.Rot = e.Rot + e.RotV*e.Turn
+		e.Vel = s.scale(e.Vel, e.Acc)
+		e.RotV = mathx.Clamp(s.mul(e.RotV, e.RotA), -e.MinRotV, e.MinRotV)
+		e.Rot = e.Rot + s.mul(e.RotV, e.Turn)
 		s.Entities[i] = e
 	}
 }
 
 func (s *theSimulation) Frame(deltaTime float64) {
+	s.Events = s.Events[:0]
 	s.processActions(deltaTime)
 	s.processCollisions()
 	s.processEphemeral(deltaTime)
 	s.processDeletions()
 	s.processPhysics(deltaTime)
+	s.processCombo(deltaTime)
+	s.processRespawns(deltaTime)
 
-	if s.Remaining == 0 && s.State == statePLAYING {
+	if s.Remaining == 0 && s.Mode == modeVERSUS {
+		s.spawnAsteroids()
+	} else if s.Remaining == 0 && s.State == statePLAYING {
 		s.State = stateNEXTLEVEL
+		if !s.Damaged {
+			for i := range s.Players {
+				pos, _ := s.spawnPoint(i)
+				s.addScore(i, pointsFlawless, pos)
+			}
+		}
+	}
+
+	if s.Hashing {
+		s.TickHash = s.StateHash()
 	}
 }
 
@@ -290,20 +520,27 @@ func (s *theSimulation) At(i int) *entity {
 }
 
 func (s *theSimulation) SpawnAsteroid() {
-	pos := s.Bounds.Max.
-		Mul(.5).
-		Add(mathx.FromHeading(mathx.Tau * rand.Float64()).Mul(128 + 128*rand.Float64()))
+	// try a few times to keep away from the ships
+	var pos mathx.Vec2
+	for try := 0; try < 8; try++ {
+		pos = s.Bounds.Max.
+			Mul(.5).
+			Add(s.scale(s.heading(s.mul(mathx.Tau, s.random())), 128+s.mul(128, s.random())))
+		if !s.nearShip(pos, respawnArea) {
+			break
+		}
+	}
 
 	s.Entities = append(s.Entities, entity{
 		ImageID: imageAsteroid,
 		Pos:     pos,
-		Turn:    mathx.Tau / 64 * (2*rand.Float64() - 1),
+		Turn:    s.mul(mathx.Tau/64, 2*s.random()-1),
 		MaxV:    100,
 		RotV:    1,
-		MinRotV: rand.Float64(),
+		MinRotV: s.random(),
 		RotA:    1,
 		Acc:     1,
-		Vel:     mathx.FromHeading(mathx.Tau * rand.Float64()).Mul(100),
+		Vel:     s.scale(s.heading(s.mul(mathx.Tau, s.random())), 100),
 		Mask:    flagASTEROID,
 		Radius:  28,
 		Pos0:    pos,
@@ -314,19 +551,19 @@ func (s *theSimulation) SpawnAsteroid() {
 
 func (s *theSimulation) SpawnDebris(pos mathx.Vec2) {
 	for i := 0; i < 4; i++ {
-		heading := (mathx.Tau / 4) * float64(i)
-		pos0 := pos.Add(mathx.FromHeading(heading).Mul(16))
+		heading := s.mul(mathx.Tau/4, float64(i))
+		pos0 := pos.Add(s.scale(s.heading(heading), debrisSpread))
 
 		s.Entities = append(s.Entities, entity{
 			ImageID: imageDebris0 + i,
 			Pos:     pos0,
-			Turn:    mathx.Tau / 32 * (2*rand.Float64() - 1),
+			Turn:    s.mul(mathx.Tau/32, 2*s.random()-1),
 			MaxV:    150,
 			RotV:    1,
-			MinRotV: rand.Float64(),
+			MinRotV: s.random(),
 			RotA:    1,
 			Acc:     1,
-			Vel:     mathx.FromHeading(mathx.Tau * rand.Float64()).Mul(150),
+			Vel:     s.scale(s.heading(s.mul(mathx.Tau, s.random())), 150),
 			Mask:    flagDEBRIS,
 			Radius:  14,
 			Pos0:    pos0,
@@ -336,28 +573,41 @@ func (s *theSimulation) SpawnDebris(pos mathx.Vec2) {
 	}
 }
 
-func (s *theSimulation) SpawnBullet(pos mathx.Vec2, rot float64) {
+func (s *theSimulation) SpawnBullet(player int, pos mathx.Vec2, rot float64) {
 	s.Entities = append(s.Entities, entity{
 		ImageID:  imageBullet,
 		Pos:      pos,
 		Acc:      1.01,
 		Rot:      rot,
-		Vel:      mathx.FromHeading(rot).Mul(200),
+		Vel:      s.scale(s.heading(rot), 200),
 		Mask:     flagEPHEMERAL | flagBULLET,
 		Radius:   4,
-		Lifetime: 0.6,
+		Lifetime: bulletLifetime,
 		Pos0:     pos,
 		Rot0:     rot,
+		Player:   player,
 	})
 }
 
-func (s *theSimulation) SpawnSpaceship() {
-	midscreen := s.Bounds.Max.Mul(0.5)
+// spawnPoint is where the ship of a player starts and which way it faces.
+// The ships are lined up side by side in the middle of the screen
+// unless they are about to fight each other.
+func (s *theSimulation) spawnPoint(p int) (mathx.Vec2, float64) {
+	if s.Mode == modeVERSUS {
+		return s.versusSpawn(p)
+	}
+	offset := 64 * (float64(p) - float64(len(s.Players)-1)/2)
+	return s.Bounds.Max.Mul(0.5).Add(mathx.Vec2{offset, 0}), -mathx.Tau / 4
+}
+
+func (s *theSimulation) SpawnSpaceship(player int) {
+	pos, rot := s.spawnPoint(player)
 	s.Entities = append(s.Entities, entity{
 		ImageID: imageShip,
-		Pos0:    midscreen,
-		Pos:     midscreen,
-		Rot:     -mathx.Tau / 4,
+		Pos0:    pos,
+		Pos:     pos,
+		Rot:     rot,
+		Rot0:    rot,
 		MinRotV: 1,
 		MaxV:    300,
 		Turn:    mathx.Tau / 4,
@@ -366,5 +616,6 @@ func (s *theSimulation) SpawnSpaceship() {
 		Acc:     0.99,
 		Mask:    flagSPACESHIP,
 		Radius:  14,
+		Player:  player,
 	})
 }
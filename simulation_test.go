@@ -0,0 +1,37 @@
+package main
+
+import "testing"
+
+func TestShipIsDestroyedOnce(t *testing.T) {
+	sim := newBareSimulation(1)
+	sim.NewGame(1, 2)
+	sim.Players[0].Lives = 1
+	sim.Reset()
+
+	// two rocks on top of the ship of player 1, ahead of it in the list
+	ship := *sim.At(sim.Ship(0))
+	other := *sim.At(sim.Ship(1))
+	sim.Entities = sim.Entities[:0]
+	sim.SpawnAsteroid()
+	sim.SpawnAsteroid()
+	sim.At(0).Pos = ship.Pos
+	sim.At(1).Pos = ship.Pos
+	sim.Entities = append(sim.Entities, ship, other)
+
+	sim.processCollisions()
+	deaths := 0
+	for _, ev := range sim.Events {
+		if ev.Code == eventDEATH {
+			deaths++
+		}
+	}
+	if deaths != 1 || sim.Players[0].Lives != 0 {
+		t.Fatalf("the ship died %d times and has %d lives left", deaths, sim.Players[0].Lives)
+	}
+
+	sim.processDeletions()
+	sim.processRespawns(10 * respawnTime)
+	if sim.Ship(0) >= 0 {
+		t.Fatal("a player without lives got a ship back")
+	}
+}
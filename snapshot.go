@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bytes"
+	"compress/flate"
+	"errors"
+	"io"
+)
+
+// Snapshots are the game states that a server sends to its clients.
+// A snapshot is sent as the difference with the last one that the client has received,
+// which is mostly zeros because most of the game state does not change between ticks.
+// The difference is compressed, so that it only takes a few bytes per moving entity.
+
+const (
+	snapshotHistory = 64      // snapshots that are kept to compute differences with
+	maxSnapshotSize = 1 << 20 // larger game states are refused
+)
+
+var errBadSnapshot = errors.New("bad snapshot")
+
+type snapshot struct {
+	Tick  int
+	State []byte
+}
+
+// snapshotRing keeps the latest snapshots by tick.
+type snapshotRing [snapshotHistory]snapshot
+
+func (h *snapshotRing) Put(tick int, state []byte) {
+	h[tick%snapshotHistory] = snapshot{tick, state}
+}
+
+func (h *snapshotRing) Get(tick int) ([]byte, bool) {
+	if tick < 0 {
+		return nil, false
+	}
+	s := h[tick%snapshotHistory]
+	return s.State, s.State != nil && s.Tick == tick
+}
+
+// encodeDelta compresses the difference of a state with a base state,
+// which is empty to send the whole state.
+func encodeDelta(state, base []byte) []byte {
+	var e deltaEncoder
+	return e.Append(nil, state, base)
+}
+
+// deltaEncoder compresses differences like encodeDelta but reuses its memory,
+// for when a difference is computed every frame.
+type deltaEncoder struct {
+	diff []byte
+	buf  bytes.Buffer
+	fw   *flate.Writer
+}
+
+// Append appends the compressed difference of a state with a base state to dst.
+func (e *deltaEncoder) Append(dst, state, base []byte) []byte {
+	e.diff = append(e.diff[:0], state...)
+	for i := range e.diff {
+		if i < len(base) {
+			e.diff[i] ^= base[i]
+		}
+	}
+
+	e.buf.Reset()
+	if e.fw == nil {
+		e.fw, _ = flate.NewWriter(&e.buf, flate.BestSpeed)
+	} else {
+		e.fw.Reset(&e.buf)
+	}
+	e.fw.Write(e.diff)
+	e.fw.Close()
+	return append(dst, e.buf.Bytes()...)
+}
+
+// decodeDelta restores a state of a given size from its difference with a base state.
+func decodeDelta(delta, base []byte, size int) ([]byte, error) {
+	if size < 0 || size > maxSnapshotSize {
+		return nil, errBadSnapshot
+	}
+
+	state := make([]byte, size)
+	fr := flate.NewReader(bytes.NewReader(delta))
+	defer fr.Close()
+	if _, err := io.ReadFull(fr, state); err != nil {
+		return nil, errBadSnapshot
+	}
+
+	for i := range state {
+		if i < len(base) {
+			state[i] ^= base[i]
+		}
+	}
+	return state, nil
+}
+
+// snapshotMessage is a snapshot as it is sent, along with the events of its tick.
+type snapshotMessage struct {
+	Tick    int
+	Base    int // tick of the snapshot that the state is a difference with, or -1
+	Missing int // players that the game is waiting for
+	State   []byte
+	Events  []simEvent
+}
+
+// write sends the state as a difference with the state of the base tick.
+func (m *snapshotMessage) write(w *saveWriter, base []byte) {
+	delta := encodeDelta(m.State, base)
+	w.i64(int64(m.Tick))
+	w.i64(int64(m.Base))
+	w.u32(uint32(m.Missing))
+	w.u32(uint32(len(m.State)))
+	w.u32(uint32(len(delta)))
+	w.bytes(delta)
+	writeEvents(w, m.Events)
+}
+
+// read restores the state from the difference with a snapshot in the history.
+func (m *snapshotMessage) read(r *saveReader, history *snapshotRing) error {
+	m.Tick = int(r.i64())
+	m.Base = int(r.i64())
+	m.Missing = int(r.u32())
+	size := int(r.u32())
+	delta := r.bytes(r.count())
+	m.Events = readEvents(r)
+	if r.err != nil {
+		return r.err
+	}
+
+	var base []byte
+	if m.Base >= 0 {
+		var ok bool
+		if base, ok = history.Get(m.Base); !ok {
+			return errBadSnapshot
+		}
+	}
+
+	var err error
+	m.State, err = decodeDelta(delta, base, size)
+	return err
+}
+
+func writeEvents(w *saveWriter, events []simEvent) {
+	w.u32(uint32(len(events)))
+	for _, ev := range events {
+		w.u32(uint32(ev.Code))
+		w.vec2(ev.Pos)
+		w.i64(int64(ev.Value))
+	}
+}
+
+func readEvents(r *saveReader) []simEvent {
+	events := make([]simEvent, r.count())
+	for i := range events {
+		events[i] = simEvent{
+			Code:  eventCode(r.u32()),
+			Pos:   r.vec2(),
+			Value: int(r.i64()),
+		}
+	}
+	return events
+}
@@ -0,0 +1,103 @@
+//go:build !headless
+// +build !headless
+
+package main
+
+import (
+	"image"
+	"image/color"
+
+	"github.com/askeladdk/pancake/graphics"
+	"github.com/askeladdk/pancake/mathx"
+)
+
+// newWhiteTexture creates a single white pixel that can be stretched and tinted.
+func newWhiteTexture() *graphics.Texture {
+	img := image.NewRGBA(image.Rect(0, 0, 1, 1))
+	img.Set(0, 0, color.White)
+	return graphics.NewTextureFromImage(img, graphics.FilterNearest)
+}
+
+// solidRect is a rectangle filled with a single colour.
+// Image must be a white texture such as the one returned by newWhiteTexture.
+type solidRect struct {
+	Image graphics.Image
+	Rect  mathx.Rectangle
+	Color color.Color
+}
+
+func (r solidRect) Len() int {
+	return 1
+}
+
+func (r solidRect) TintColorAt(i int) color.Color {
+	return r.Color
+}
+
+func (r solidRect) TextureAt(i int) *graphics.Texture {
+	return r.Image.Texture()
+}
+
+func (r solidRect) TextureRegionAt(i int) graphics.TextureRegion {
+	return r.Image.TextureRegion()
+}
+
+func (r solidRect) ModelViewAt(i int) mathx.Aff3 {
+	size := r.Rect.Max.Sub(r.Rect.Min)
+	return mathx.
+		ScaleAff3(size).
+		Translated(r.Rect.Min.Add(size.Mul(0.5)))
+}
+
+func (r solidRect) OriginAt(i int) mathx.Vec2 {
+	return mathx.Vec2{}
+}
+
+func (r solidRect) ZOrderAt(i int) float64 {
+	return 0
+}
+
+// solidRects are rectangles that are filled with colours of their own.
+type solidRects struct {
+	Image  graphics.Image
+	Rects  []mathx.Rectangle
+	Colors []color.Color
+}
+
+func (r *solidRects) Clear() {
+	r.Rects = r.Rects[:0]
+	r.Colors = r.Colors[:0]
+}
+
+func (r *solidRects) Add(rect mathx.Rectangle, c color.Color) {
+	r.Rects = append(r.Rects, rect)
+	r.Colors = append(r.Colors, c)
+}
+
+func (r *solidRects) Len() int {
+	return len(r.Rects)
+}
+
+func (r *solidRects) TintColorAt(i int) color.Color {
+	return r.Colors[i]
+}
+
+func (r *solidRects) TextureAt(i int) *graphics.Texture {
+	return r.Image.Texture()
+}
+
+func (r *solidRects) TextureRegionAt(i int) graphics.TextureRegion {
+	return r.Image.TextureRegion()
+}
+
+func (r *solidRects) ModelViewAt(i int) mathx.Aff3 {
+	return solidRect{Rect: r.Rects[i]}.ModelViewAt(0)
+}
+
+func (r *solidRects) OriginAt(i int) mathx.Vec2 {
+	return mathx.Vec2{}
+}
+
+func (r *solidRects) ZOrderAt(i int) float64 {
+	return 0
+}
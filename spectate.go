@@ -0,0 +1,235 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// Spectators watch a game that runs somewhere else. The game publishes a snapshot
+// of every frame on a TCP port, as the difference with the one before, and spectators
+// only read them. Every spectator is served by its own goroutine so that a slow one
+// misses frames instead of holding up the game.
+
+const feedQueueSize = 64 // frames that are queued for a spectator before they are dropped
+
+// spectatorFeed publishes a game to the spectators that connect to it.
+type spectatorFeed struct {
+	Listener net.Listener
+	Tick     int
+	mu       sync.Mutex
+	watchers map[net.Conn]chan snapshotMessage
+	closed   bool
+}
+
+// listenFeed publishes a game on a TCP address such as ":7778".
+func listenFeed(addr string) (*spectatorFeed, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &spectatorFeed{
+		Listener: ln,
+		watchers: make(map[net.Conn]chan snapshotMessage),
+	}
+	go f.accept()
+	return f, nil
+}
+
+func (f *spectatorFeed) accept() {
+	for {
+		conn, err := f.Listener.Accept()
+		if err != nil {
+			return
+		}
+
+		queue := make(chan snapshotMessage, feedQueueSize)
+		f.mu.Lock()
+		if f.closed {
+			f.mu.Unlock()
+			conn.Close()
+			return
+		}
+		f.watchers[conn] = queue
+		f.mu.Unlock()
+		go f.serve(conn, queue)
+	}
+}
+
+// serve sends the frames to a spectator until it disconnects.
+func (f *spectatorFeed) serve(conn net.Conn, queue chan snapshotMessage) {
+	defer f.remove(conn)
+
+	var base []byte
+	baseTick := -1
+	w := bufio.NewWriter(conn)
+	for msg := range queue {
+		msg.Base = baseTick
+		data := makePacket(packetSNAPSHOT, func(w *saveWriter) {
+			msg.write(w, base)
+		})
+
+		conn.SetWriteDeadline(time.Now().Add(netTimeout))
+		if err := writeFrame(w, data); err != nil {
+			return
+		} else if len(queue) == 0 {
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+		base, baseTick = msg.State, msg.Tick
+	}
+}
+
+func (f *spectatorFeed) remove(conn net.Conn) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if queue, ok := f.watchers[conn]; ok {
+		close(queue)
+		delete(f.watchers, conn)
+	}
+	conn.Close()
+}
+
+// Publish sends the state of the simulation after a frame to every spectator.
+func (f *spectatorFeed) Publish(sim *theSimulation) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	tick := f.Tick
+	f.Tick++
+	if len(f.watchers) == 0 {
+		return
+	}
+
+	state, _ := sim.MarshalBinary()
+	events := append([]simEvent(nil), sim.Events...)
+	msg := snapshotMessage{Tick: tick, State: state, Events: events}
+	for _, queue := range f.watchers {
+		select {
+		case queue <- msg:
+		default: // the spectator is too slow, so it skips the frames it has not seen yet
+			for len(queue) > 0 {
+				<-queue
+			}
+			queue <- msg
+		}
+	}
+}
+
+// Close disconnects the spectators and stops the goroutines that serve them.
+func (f *spectatorFeed) Close() error {
+	err := f.Listener.Close()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.closed = true
+	for conn, queue := range f.watchers {
+		close(queue)
+		delete(f.watchers, conn)
+		conn.Close()
+	}
+	return err
+}
+
+// feedFrame is a frame of a feed and when it arrived.
+type feedFrame struct {
+	snapshotMessage
+	Arrived time.Time
+}
+
+// feedLink receives the frames of a game from a spectator feed.
+type feedLink struct {
+	Conn     net.Conn
+	Frames   []feedFrame // frames that have arrived but are not shown yet
+	Err      error
+	history  snapshotRing
+	packets  chan []byte
+	done     chan struct{} // closed by Close, so that the receiver stops handing over packets
+	lastSeen time.Time
+}
+
+// dialFeed connects to the spectator feed at an address such as "localhost:7778".
+func dialFeed(addr string) (*feedLink, error) {
+	conn, err := net.DialTimeout("tcp", addr, netTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &feedLink{
+		Conn:     conn,
+		packets:  make(chan []byte, 256),
+		done:     make(chan struct{}),
+		lastSeen: time.Now(),
+	}
+	go l.receive()
+	return l, nil
+}
+
+func (l *feedLink) receive() {
+	r := bufio.NewReader(l.Conn)
+	for {
+		data, err := readFrame(r)
+		if err != nil {
+			close(l.packets)
+			return
+		}
+
+		select {
+		case l.packets <- data:
+		case <-l.done:
+			return
+		}
+	}
+}
+
+// Receive queues the frames that have arrived.
+// A game that has nothing to show, such as a paused one, is not a lost connection.
+func (l *feedLink) Receive() {
+	now := time.Now()
+	for l.Err == nil {
+		select {
+		case data, ok := <-l.packets:
+			if !ok {
+				l.Err = errServerLeft
+			} else {
+				l.lastSeen = now
+				l.handle(now, data)
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (l *feedLink) handle(now time.Time, data []byte) {
+	kind, r, ok := parsePacket(data)
+	if !ok || kind != packetSNAPSHOT {
+		return
+	}
+
+	var msg snapshotMessage
+	if err := msg.read(r, &l.history); err != nil {
+		l.Err = fmt.Errorf("bad frame from the feed: %v", err)
+		return
+	}
+	l.history.Put(msg.Tick, msg.State)
+	l.Frames = append(l.Frames, feedFrame{msg, now})
+}
+
+// Next removes the frames that arrived before a time from the queue and returns them.
+func (l *feedLink) Next(until time.Time) []feedFrame {
+	n := 0
+	for n < len(l.Frames) && !l.Frames[n].Arrived.After(until) {
+		n++
+	}
+	frames := append([]feedFrame(nil), l.Frames[:n]...)
+	l.Frames = append(l.Frames[:0], l.Frames[n:]...)
+	return frames
+}
+
+func (l *feedLink) Close() error {
+	close(l.done)
+	return l.Conn.Close()
+}
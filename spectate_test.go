@@ -0,0 +1,59 @@
+package main
+
+import (
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestClosedFeedStopsServing(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	feed, err := listenFeed("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	link, err := dialFeed(feed.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// publish more frames than the spectator takes in, because it stopped watching
+	sim := newBareSimulation(1)
+	sim.Reset()
+	for i := 0; i < 10000 && len(link.packets) < cap(link.packets); i++ {
+		feed.Publish(sim)
+		time.Sleep(100 * time.Microsecond)
+	}
+	if len(link.packets) < cap(link.packets) {
+		t.Fatal("the frames did not arrive")
+	}
+	for i := 0; i < 10; i++ {
+		feed.Publish(sim)
+	}
+	for i := 0; i < 1000 && feed.queued() > 0; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+	link.Close()
+	feed.Close()
+	feed.Publish(sim)
+
+	for i := 0; i < 1000 && runtime.NumGoroutine() > before; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	if n := runtime.NumGoroutine(); n > before {
+		t.Fatalf("%d goroutines are still running after the feed was closed", n-before)
+	}
+}
+
+// queued returns the number of frames that wait to be sent to the spectators.
+func (f *spectatorFeed) queued() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n := 0
+	for _, queue := range f.watchers {
+		n += len(queue)
+	}
+	return n
+}
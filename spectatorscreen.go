@@ -0,0 +1,207 @@
+//go:build !headless
+// +build !headless
+
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/askeladdk/pancake"
+	"github.com/askeladdk/pancake/graphics2d"
+	"github.com/askeladdk/pancake/input"
+	"github.com/askeladdk/pancake/mathx"
+)
+
+const (
+	defaultSpectateDelay = 500 * time.Millisecond
+	cameraSpeed          = 320 // pixels per second at normal zoom
+	maxCameraZoom        = 4
+)
+
+// cameraView draws the entities of a drawable through a camera.
+type cameraView struct {
+	graphics2d.Drawable
+	View func(mathx.Aff3) mathx.Aff3
+}
+
+func (v cameraView) ModelViewAt(i int) mathx.Aff3 {
+	return v.View(v.Drawable.ModelViewAt(i))
+}
+
+const (
+	cameraLeft = 1 << iota
+	cameraRight
+	cameraUp
+	cameraDown
+)
+
+// freeCamera lets a spectator move around and zoom in on the playing field.
+type freeCamera struct {
+	On     bool
+	Center mathx.Vec2
+	Zoom   float64
+	held   int
+}
+
+func (c *freeCamera) Reset(bounds mathx.Rectangle) {
+	c.Center = bounds.Min.Add(bounds.Max).Mul(0.5)
+	c.Zoom = 1
+	c.held = 0
+}
+
+// Key moves and zooms the camera and reports whether it used the key.
+func (c *freeCamera) Key(ev pancake.KeyEvent) bool {
+	var dir int
+	switch ev.Key {
+	case input.KeyLeft, input.KeyA:
+		dir = cameraLeft
+	case input.KeyRight, input.KeyD:
+		dir = cameraRight
+	case input.KeyUp, input.KeyW:
+		dir = cameraUp
+	case input.KeyDown, input.KeyS:
+		dir = cameraDown
+	case input.KeyEqual, input.KeyE:
+		if ev.Flags.Pressed() && c.Zoom < maxCameraZoom {
+			c.Zoom *= 2
+		}
+		return true
+	case input.KeyMinus, input.KeyQ:
+		if ev.Flags.Pressed() && c.Zoom > 1 {
+			c.Zoom /= 2
+		}
+		return true
+	default:
+		return false
+	}
+
+	c.held = int(toggleFlag(uint32(c.held), uint32(dir), ev.Flags.Down()))
+	return true
+}
+
+// Frame moves the camera over the playing field while the keys are held.
+func (c *freeCamera) Frame(dt float64, bounds mathx.Rectangle) {
+	var v mathx.Vec2
+	if c.held&cameraLeft != 0 {
+		v[0]--
+	}
+	if c.held&cameraRight != 0 {
+		v[0]++
+	}
+	if c.held&cameraUp != 0 {
+		v[1]--
+	}
+	if c.held&cameraDown != 0 {
+		v[1]++
+	}
+
+	c.Center = c.Center.Add(v.Mul(cameraSpeed * dt / c.Zoom))
+	c.Center[0] = mathx.Clamp(c.Center[0], bounds.Min[0], bounds.Max[0])
+	c.Center[1] = mathx.Clamp(c.Center[1], bounds.Min[1], bounds.Max[1])
+}
+
+// View places what the camera sees in the middle of the screen.
+func (c *freeCamera) View(bounds mathx.Rectangle) func(mathx.Aff3) mathx.Aff3 {
+	mid := bounds.Min.Add(bounds.Max).Mul(0.5)
+	return func(m mathx.Aff3) mathx.Aff3 {
+		return m.Translated(c.Center.Neg()).Scaled(mathx.Vec2{c.Zoom, c.Zoom}).Translated(mid)
+	}
+}
+
+// spectatorScreen shows a game that is published somewhere else, a little behind
+// so that the frames can be shown at an even pace. Nothing that is shown can be changed.
+type spectatorScreen struct {
+	*gameScreen
+	Link   *feedLink
+	Delay  time.Duration
+	Camera freeCamera
+	Shown  bool // whether a frame has arrived
+	Leave  bool
+}
+
+func newSpectatorScreen(res *resources, sess *session, link *feedLink, delay time.Duration) *spectatorScreen {
+	s := &spectatorScreen{
+		gameScreen: newGameScreen(res, sess),
+		Link:       link,
+		Delay:      delay,
+	}
+	s.Camera.Reset(res.Bounds)
+	return s
+}
+
+func (s *spectatorScreen) Begin() {
+	s.Leave = false
+	s.Floaters.Clear()
+}
+
+func (s *spectatorScreen) Key(ev pancake.KeyEvent) error {
+	switch {
+	case ev.Key == input.KeyEscape:
+		s.Leave = s.Leave || ev.Flags.Pressed()
+	case ev.Key == input.KeyC && ev.Flags.Pressed():
+		s.Camera.On = !s.Camera.On
+		s.Camera.Reset(s.Res.Bounds)
+	case s.Camera.On:
+		s.Camera.Key(ev)
+	}
+	return nil
+}
+
+func (s *spectatorScreen) Frame(ev pancake.FrameEvent) (screenOp, error) {
+	s.Link.Receive()
+	if err := s.Link.Err; err != nil || s.Leave {
+		if err != nil {
+			fmt.Println(err)
+		}
+		s.Link.Close()
+		return replaceAll(newTitleScreen(s.Res, s.session)), nil
+	}
+
+	var events []simEvent
+	frames := s.Link.Next(time.Now().Add(-s.Delay))
+	for _, f := range frames {
+		events = append(events, f.Events...)
+	}
+	if n := len(frames); n > 0 {
+		if err := s.Sim.UnmarshalBinary(frames[n-1].State); err != nil {
+			fmt.Println(err)
+		}
+		s.Shown = true
+	}
+
+	for _, e := range events {
+		if snd, ok := eventSounds[e.Code]; ok {
+			s.Sim.PlaySound(snd)
+		}
+	}
+	s.Floaters.Frame(ev.DeltaTime)
+	s.Floaters.AddEvents(events)
+	s.Camera.Frame(ev.DeltaTime, s.Res.Bounds)
+
+	s.printStatus()
+	if !s.Shown {
+		fmt.Fprintf(s.Text, "\nWaiting for the game at %s...", s.Link.Conn.RemoteAddr())
+	} else if s.Camera.On {
+		fmt.Fprintf(s.Text, "\nFree camera: arrows move, +/- zoom, C returns")
+	} else {
+		fmt.Fprintf(s.Text, "\nSpectating, C for a free camera")
+	}
+	return screenOp{}, nil
+}
+
+func (s *spectatorScreen) Draw(ev pancake.DrawEvent) error {
+	s.Shader.Begin()
+	s.Sim.Alpha = ev.Alpha
+	s.Drawer.Draw(s.Background)
+	if s.Camera.On {
+		// floating texts are placed on the screen and would not line up with a moving camera
+		s.Drawer.Draw(cameraView{s.Sim, s.Camera.View(s.Res.Bounds)})
+	} else {
+		s.Drawer.Draw(s.Sim)
+		s.Floaters.Draw(s.Drawer)
+	}
+	s.Drawer.Draw(s.Text)
+	s.Shader.End()
+	return nil
+}
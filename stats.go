@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+const statsFile = "stats.jsonl"
+
+// runStats collects statistics about a run by observing the simulation every frame.
+type runStats struct {
+	Seed               int64     `json:"seed"`
+	Date               time.Time `json:"date"`
+	FinalScore         int       `json:"final_score"`
+	FinalLevel         int       `json:"final_level"`
+	ShotsFired         int       `json:"shots_fired"`
+	Hits               int       `json:"hits"`
+	AsteroidsDestroyed int       `json:"asteroids_destroyed"`
+	DebrisDestroyed    int       `json:"debris_destroyed"`
+	Bounces            int       `json:"bounces"`
+	Hyperspaces        int       `json:"hyperspaces"`
+	Distance           float64   `json:"distance"`
+	LevelTimes         []float64 `json:"level_times"`
+	CauseOfDeath       string    `json:"cause_of_death,omitempty"`
+	LevelTime          float64   `json:"-"`
+}
+
+// Accuracy is the fraction of bullets that hit something.
+func (rs *runStats) Accuracy() float64 {
+	if rs.ShotsFired == 0 {
+		return 0
+	}
+	return float64(rs.Hits) / float64(rs.ShotsFired)
+}
+
+// Observe accounts for what happened during the last simulation frame.
+func (rs *runStats) Observe(sim *theSimulation, deltaTime float64) {
+	rs.LevelTime += deltaTime
+
+	for _, ev := range sim.Events {
+		switch ev.Code {
+		case eventFIRE:
+			rs.ShotsFired++
+		case eventHIT:
+			rs.Hits++
+		case eventDESTROYED:
+			if ev.Value&flagASTEROID != 0 {
+				rs.AsteroidsDestroyed++
+			} else {
+				rs.DebrisDestroyed++
+			}
+		case eventBOUNCE:
+			rs.Bounces++
+		case eventHYPERSPACE:
+			rs.Hyperspaces++
+		case eventDEATH:
+			if ev.Value&flagASTEROID != 0 {
+				rs.CauseOfDeath = "asteroid"
+			} else {
+				rs.CauseOfDeath = "debris"
+			}
+		}
+	}
+
+	for _, e := range sim.Entities {
+		if e.Mask&flagSPACESHIP != 0 {
+			rs.Distance += e.Vel.Len() * deltaTime
+		}
+	}
+}
+
+// EndLevel is called when the level is cleared or the game is over.
+func (rs *runStats) EndLevel(sim *theSimulation) {
+	rs.LevelTimes = append(rs.LevelTimes, rs.LevelTime)
+	rs.LevelTime = 0
+	rs.FinalScore = sim.Score
+	rs.FinalLevel = 1 + sim.Level
+}
+
+// PrintLevel writes a summary of the last level.
+func (rs *runStats) PrintLevel(w io.Writer) {
+	if n := len(rs.LevelTimes); n > 0 {
+		fmt.Fprintf(w, "Level time: %.1fs\n", rs.LevelTimes[n-1])
+	}
+	fmt.Fprintf(w, "Accuracy so far: %.0f%%\n", 100*rs.Accuracy())
+}
+
+// Print writes a summary of the whole run.
+func (rs *runStats) Print(w io.Writer) {
+	var total float64
+	for _, t := range rs.LevelTimes {
+		total += t
+	}
+
+	fmt.Fprintf(w, "Shots fired: %d, hits: %d, accuracy: %.0f%%\n", rs.ShotsFired, rs.Hits, 100*rs.Accuracy())
+	fmt.Fprintf(w, "Destroyed %d asteroids and %d debris\n", rs.AsteroidsDestroyed, rs.DebrisDestroyed)
+	fmt.Fprintf(w, "Bounces: %d, hyperspace jumps: %d\n", rs.Bounces, rs.Hyperspaces)
+	fmt.Fprintf(w, "Distance travelled: %.0f pixels\n", rs.Distance)
+	fmt.Fprintf(w, "Time played: %.1fs\n", total)
+	if rs.CauseOfDeath != "" {
+		fmt.Fprintf(w, "Killed by %s\n", rs.CauseOfDeath)
+	}
+}
+
+// exportStats appends the statistics of a run as a line of JSON to the stats file
+// in the user data directory, ready for analysis.
+func exportStats(rs *runStats) error {
+	filename, err := userFilePath(statsFile)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(rs)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -1,30 +1,59 @@
+//go:build !headless
+// +build !headless
+
 package main
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/askeladdk/pancake/input"
 	"github.com/askeladdk/pancake/mathx"
 
 	"github.com/askeladdk/pancake"
 	"github.com/askeladdk/pancake/graphics"
-	gl "github.com/askeladdk/pancake/graphics/opengl"
-	"github.com/askeladdk/pancake/graphics2d"
 	"github.com/askeladdk/pancake/text"
 )
 
 type titleScreen struct {
-	Background staticImage
-	Title      staticImage
-	Drawer     *graphics2d.Drawer
-	Shader     *graphics.ShaderProgram
-	Text       *text.Text
-	Start      bool
+	*session
+	Res          *resources
+	Background   staticImage
+	Title        staticImage
+	Drawer       *tintDrawer
+	Shader       *graphics.ShaderProgram
+	Text         *text.Text
+	Start        bool
+	Players      int
+	HighScores   bool
+	Achievements bool
+	Versus       bool
+	Replay       bool
+}
+
+func newTitleScreen(res *resources, sess *session) *titleScreen {
+	return &titleScreen{
+		Res:        res,
+		session:    sess,
+		Background: res.Background,
+		Title:      res.Title,
+		Drawer:     res.Drawer,
+		Shader:     res.Shader,
+		Text:       text.NewText(res.Font12),
+	}
 }
 
 func (s *titleScreen) Begin() {
+	s.Start = false
+	s.Players = 1
+	s.HighScores = false
+	s.Achievements = false
+	s.Versus = false
+	s.Replay = false
 	s.Text.Clear()
-	s.Text.Pos = mathx.Vec2{4, 360 - 5*s.Text.LineHeight - 4}
+	s.Text.Pos = mathx.Vec2{4, s.Res.Bounds.Max[1] - 8*s.Text.LineHeight - 4}
+	fmt.Fprintf(s.Text, "Press 2 to play together on one keyboard or V to fight each other.\n")
+	fmt.Fprintf(s.Text, "Press H to view the high scores, A for achievements or R to watch your last run.\n\n")
 	fmt.Fprintf(s.Text, "Music by Eric Matyas (www.soundimage.org)\n")
 	fmt.Fprintf(s.Text, "Sprites by CDmir (www.opengameart.org)\n")
 	fmt.Fprintf(s.Text, "Background by OdinTdh (www.opengameart.org)\n")
@@ -32,29 +61,65 @@ func (s *titleScreen) Begin() {
 	fmt.Fprintf(s.Text, "Programmed by Askeladd (github.com/askeladdk/asteroids)")
 }
 
-func (s *titleScreen) End() {
-	s.Text.Pos[1] = 0
-}
+func (s *titleScreen) End() {}
 
 func (s *titleScreen) Key(ev pancake.KeyEvent) error {
+	if !ev.Flags.Pressed() {
+		return nil
+	}
+
 	switch ev.Key {
 	case input.KeyEscape:
 		return pancake.ErrQuit
+	case input.KeyH:
+		s.HighScores = true
+		return nil
+	case input.KeyA:
+		s.Achievements = true
+		return nil
+	case input.KeyV:
+		s.Versus = true
+		return nil
+	case input.KeyR:
+		s.Replay = true
+		return nil
+	case input.Key2:
+		s.Players = 2
+		s.Start = true
+		return nil
 	default:
 		s.Start = true
 		return nil
 	}
 }
 
-func (s *titleScreen) Frame(ev pancake.FrameEvent) (screen, error) {
-	if s.Start {
-		return globalGameScreen, nil
+func (s *titleScreen) Frame(ev pancake.FrameEvent) (screenOp, error) {
+	if s.HighScores {
+		s.HighScores = false
+		return push(newHighScoresScreen(s.Res)), nil
+	} else if s.Achievements {
+		s.Achievements = false
+		return push(newAchievementsScreen(s.Res)), nil
+	} else if s.Versus {
+		s.Versus = false
+		return push(newVersusSetupScreen(s.Res, s.session)), nil
+	} else if s.Replay {
+		s.Replay = false
+		if filename, err := latestRunLog(); err != nil {
+			fmt.Println(err)
+		} else if l, err := loadRunLog(filename); err != nil {
+			fmt.Println(err)
+		} else {
+			return push(newPlaybackScreen(s.Res, s.session, l)), nil
+		}
+	} else if s.Start {
+		s.NewGame(time.Now().UnixNano(), s.Players)
+		return replace(newGameScreen(s.Res, s.session)), nil
 	}
-	return nil, nil
+	return screenOp{}, nil
 }
 
 func (s *titleScreen) Draw(ev pancake.DrawEvent) error {
-	gl.Clear(gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT)
 	s.Shader.Begin()
 	s.Drawer.Draw(s.Background)
 	s.Drawer.Draw(s.Title)
@@ -0,0 +1,86 @@
+//go:build !headless
+// +build !headless
+
+package main
+
+import (
+	"fmt"
+	"image/color"
+
+	"github.com/askeladdk/pancake/mathx"
+	"github.com/askeladdk/pancake/text"
+)
+
+const (
+	toastTime     = 3.0  // seconds that a toast is shown
+	toastFadeTime = 0.25 // seconds to fade in and out
+	toastWidth    = 320
+)
+
+// toaster announces unlocked achievements one after the other at the top of the screen.
+type toaster struct {
+	Text       *text.Text
+	Background solidRect
+	Queue      []*achievementDef
+	Age        float64
+	Showing    bool
+}
+
+func newToaster(res *resources) *toaster {
+	t := text.NewText(res.Font12)
+	x := res.Midscreen()[0] - toastWidth/2
+	t.Pos = mathx.Vec2{x + 8, 8}
+	return &toaster{
+		Text: t,
+		Background: solidRect{
+			Image: res.White,
+			Rect: mathx.Rectangle{
+				Min: mathx.Vec2{x, 4},
+				Max: mathx.Vec2{x + toastWidth, 12 + 2*t.LineHeight},
+			},
+			Color: color.RGBA{0x20, 0x20, 0x40, 0xc0},
+		},
+	}
+}
+
+// Add queues achievements to be announced.
+func (t *toaster) Add(defs ...*achievementDef) {
+	t.Queue = append(t.Queue, defs...)
+}
+
+func (t *toaster) Frame(deltaTime float64) {
+	if t.Showing {
+		if t.Age += deltaTime; t.Age < toastTime {
+			return
+		}
+		t.Showing = false
+	}
+
+	if len(t.Queue) == 0 {
+		return
+	}
+
+	d := t.Queue[0]
+	t.Queue = t.Queue[1:]
+	t.Text.Clear()
+	fmt.Fprintf(t.Text, "Achievement unlocked: %s\n%s", d.Title, d.Description)
+	t.Age = 0
+	t.Showing = true
+}
+
+func (t *toaster) Draw(drawer *tintDrawer) {
+	if !t.Showing {
+		return
+	}
+
+	v := 1.0
+	if t.Age < toastFadeTime {
+		v = t.Age / toastFadeTime
+	} else if t.Age > toastTime-toastFadeTime {
+		v = (toastTime - t.Age) / toastFadeTime
+	}
+
+	tint := opacity(v)
+	drawer.Draw(tintedDrawable{t.Background, tint})
+	drawer.Draw(tintedDrawable{t.Text, tint})
+}
@@ -0,0 +1,152 @@
+//go:build !headless
+// +build !headless
+
+package main
+
+import (
+	"image/color"
+
+	"github.com/askeladdk/pancake"
+	"github.com/askeladdk/pancake/graphics2d"
+)
+
+type transitionEffect int
+
+const (
+	effectCUT transitionEffect = iota
+	effectFADE
+	effectDISSOLVE
+	effectWIPE
+)
+
+// tintDrawer multiplies the tint colour of everything it draws.
+// Screen transitions use it to fade whole screens in and out.
+type tintDrawer struct {
+	*graphics2d.Drawer
+	Tint color.Color
+}
+
+func newTintDrawer(drawer *graphics2d.Drawer) *tintDrawer {
+	return &tintDrawer{
+		Drawer: drawer,
+		Tint:   color.White,
+	}
+}
+
+func (d *tintDrawer) Draw(x graphics2d.Drawable) {
+	if d.Tint == color.White {
+		d.Drawer.Draw(x)
+	} else {
+		d.Drawer.Draw(tintedDrawable{x, d.Tint})
+	}
+}
+
+type tintedDrawable struct {
+	graphics2d.Drawable
+	Tint color.Color
+}
+
+func (t tintedDrawable) TintColorAt(i int) color.Color {
+	return mulColor(t.Drawable.TintColorAt(i), t.Tint)
+}
+
+func mulColor(a, b color.Color) color.Color {
+	r0, g0, b0, a0 := a.RGBA()
+	r1, g1, b1, a1 := b.RGBA()
+	return color.RGBA64{
+		R: uint16(r0 * r1 / 0xffff),
+		G: uint16(g0 * g1 / 0xffff),
+		B: uint16(b0 * b1 / 0xffff),
+		A: uint16(a0 * a1 / 0xffff),
+	}
+}
+
+// brightness returns a tint that darkens towards black as v goes to zero.
+func brightness(v float64) color.Color {
+	c := uint8(0xff * v)
+	return color.RGBA{c, c, c, 0xff}
+}
+
+// opacity returns a tint that makes things transparent as v goes to zero.
+func opacity(v float64) color.Color {
+	c := uint8(0xff * v)
+	return color.RGBA{c, c, c, c}
+}
+
+// transition animates the change from one set of visible screens to another.
+// Both sets are frozen while it runs.
+type transition struct {
+	Res      *resources
+	From     []screen
+	To       []screen
+	Effect   transitionEffect
+	Duration float64
+	Time     float64
+}
+
+func (t *transition) Done() bool {
+	return t.Time >= t.Duration
+}
+
+func (t *transition) Frame(ev pancake.FrameEvent) {
+	t.Time += ev.DeltaTime
+}
+
+func (t *transition) Draw() error {
+	drawer := t.Res.Drawer
+	defer func() { drawer.Tint = color.White }()
+
+	p := t.Time / t.Duration
+	if p > 1 {
+		p = 1
+	}
+
+	switch t.Effect {
+	case effectFADE:
+		if p < 0.5 {
+			drawer.Tint = brightness(1 - 2*p)
+			return drawScreens(t.From)
+		}
+		drawer.Tint = brightness(2*p - 1)
+		return drawScreens(t.To)
+	case effectDISSOLVE:
+		if err := drawScreens(t.From); err != nil {
+			return err
+		}
+		drawer.Tint = opacity(p)
+		return drawScreens(t.To)
+	case effectWIPE:
+		bar := solidRect{
+			Image: t.Res.White,
+			Rect:  t.Res.Bounds,
+			Color: color.Black,
+		}
+		width := bar.Rect.Max[0] - bar.Rect.Min[0]
+		screens := t.From
+		if p < 0.5 {
+			bar.Rect.Max[0] = bar.Rect.Min[0] + width*2*p
+		} else {
+			screens = t.To
+			bar.Rect.Min[0] += width * (2*p - 1)
+		}
+		if err := drawScreens(screens); err != nil {
+			return err
+		}
+		t.Res.Shader.Begin()
+		drawer.Draw(bar)
+		t.Res.Shader.End()
+		return nil
+	default:
+		return drawScreens(t.To)
+	}
+}
+
+// drawScreens draws frozen screens from bottom to top.
+func drawScreens(screens []screen) error {
+	for _, s := range screens {
+		if err := s.Draw(pancake.DrawEvent{Alpha: 1}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package main
+
+import "github.com/askeladdk/pancake/mathx"
+
+type gameMode int
+
+const (
+	modeCOOP   gameMode = iota // everybody against the asteroids
+	modeVERSUS                 // everybody against each other
+)
+
+const (
+	pointsShipKill = 1000
+	bulletArmTime  = 0.2 // seconds before a bullet can hit the ship that fired it
+	versusRadius   = 160 // distance of the versus starting points from the centre
+	maxRoundsToWin = 9
+	maxVersusLives = 5
+	maxVersusRocks = 12
+)
+
+// versusRules configure a versus match.
+type versusRules struct {
+	RoundsToWin  int  // the first to win this many rounds wins the match
+	Lives        int  // ships per player per round, more than one means respawns
+	Asteroids    int  // asteroids on the field, which are replenished when they run out
+	FriendlyFire bool // bullets can also hit the ship that fired them
+}
+
+func defaultVersusRules() versusRules {
+	return versusRules{
+		RoundsToWin: 3,
+		Lives:       1,
+		Asteroids:   3,
+	}
+}
+
+// Valid reports whether the rules are within the limits of the versus setup screen.
+func (r versusRules) Valid() bool {
+	return r.RoundsToWin >= 1 && r.RoundsToWin <= maxRoundsToWin &&
+		r.Lives >= 1 && r.Lives <= maxVersusLives &&
+		r.Asteroids >= 0 && r.Asteroids <= maxVersusRocks
+}
+
+// NewMatch starts a versus match. The level counts the rounds.
+func (s *theSimulation) NewMatch(seed int64, players int, rules versusRules) {
+	s.NewGame(seed, players)
+	s.Mode = modeVERSUS
+	s.Rules = rules
+}
+
+// beginRound gives every player a fresh set of ships.
+func (s *theSimulation) beginRound() {
+	s.RoundWinner = -1
+	for i := range s.Players {
+		s.Players[i].Lives = s.Rules.Lives
+	}
+}
+
+// canHit reports whether a bullet can destroy a ship.
+func (s *theSimulation) canHit(bullet, ship *entity) bool {
+	if s.Mode != modeVERSUS {
+		return false
+	} else if bullet.Player != ship.Player {
+		return true
+	}
+	return s.Rules.FriendlyFire && bulletLifetime-bullet.Lifetime >= bulletArmTime
+}
+
+// checkRound ends the round when at most one player has ships left.
+// A round that is already over is not scored again.
+func (s *theSimulation) checkRound() {
+	if s.State == stateROUNDOVER {
+		return
+	}
+
+	winner := -1
+	for i, pl := range s.Players {
+		if pl.Lives > 0 {
+			if winner >= 0 {
+				return
+			}
+			winner = i
+		}
+	}
+
+	s.State = stateROUNDOVER
+	s.RoundWinner = winner
+	if winner >= 0 {
+		s.Players[winner].Wins++
+	}
+}
+
+// MatchWinner returns the player that has won enough rounds, or -1 if the match goes on.
+func (s *theSimulation) MatchWinner() int {
+	for i, pl := range s.Players {
+		if pl.Wins >= s.Rules.RoundsToWin {
+			return i
+		}
+	}
+	return -1
+}
+
+// versusSpawn places the players around the centre of the screen facing inwards.
+func (s *theSimulation) versusSpawn(p int) (mathx.Vec2, float64) {
+	heading := mathx.Tau*float64(p)/float64(len(s.Players)) + mathx.Tau/2
+	pos := s.Bounds.Max.Mul(0.5).Add(s.scale(s.heading(heading), versusRadius))
+	return pos, heading + mathx.Tau/2
+}
@@ -0,0 +1,34 @@
+package main
+
+import "testing"
+
+func TestRoundIsScoredOnce(t *testing.T) {
+	sim := newBareSimulation(1)
+	sim.NewMatch(1, 2, defaultVersusRules())
+	sim.Reset()
+
+	sim.Players[1].Lives = 0
+	sim.checkRound()
+	sim.checkRound()
+	if sim.State != stateROUNDOVER || sim.RoundWinner != 0 || sim.Players[0].Wins != 1 {
+		t.Fatalf("state %d, winner %d with %d wins", sim.State, sim.RoundWinner, sim.Players[0].Wins)
+	}
+}
+
+func TestSaveWithBadRulesIsRejected(t *testing.T) {
+	for _, rules := range []versusRules{
+		{RoundsToWin: 0, Lives: 1},
+		{RoundsToWin: 1, Lives: maxVersusLives + 1},
+		{RoundsToWin: 1, Lives: 1, Asteroids: -1},
+	} {
+		sim := newBareSimulation(1)
+		sim.NewMatch(1, 2, rules)
+		data, err := sim.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := newBareSimulation(1).UnmarshalBinary(data); err != errBadSave {
+			t.Errorf("a save with rules %+v was accepted: %v", rules, err)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+//go:build !headless
+// +build !headless
+
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/askeladdk/pancake"
+	"github.com/askeladdk/pancake/graphics"
+	"github.com/askeladdk/pancake/input"
+	"github.com/askeladdk/pancake/text"
+)
+
+const (
+	versusRounds = iota
+	versusLives
+	versusAsteroids
+	versusFriendlyFire
+	versusStart
+	versusBack
+)
+
+// versusSetupScreen lets the players agree on the rules before a versus match.
+type versusSetupScreen struct {
+	*session
+	Res        *resources
+	Rules      *versusRules
+	Text       *text.Text
+	Drawer     *tintDrawer
+	Shader     *graphics.ShaderProgram
+	Background staticImage
+	Menu       menu
+	Done       bool
+}
+
+func newVersusSetupScreen(res *resources, sess *session) *versusSetupScreen {
+	return &versusSetupScreen{
+		Res:        res,
+		session:    sess,
+		Rules:      &res.Versus,
+		Text:       res.newMenuText(),
+		Drawer:     res.Drawer,
+		Shader:     res.Shader,
+		Background: res.Background,
+	}
+}
+
+func (s *versusSetupScreen) Begin() {
+	s.Done = false
+	s.Menu.Reset()
+	s.print()
+}
+
+func (s *versusSetupScreen) End() {}
+
+func (s *versusSetupScreen) Key(ev pancake.KeyEvent) error {
+	defer s.print()
+
+	if !ev.Flags.Pressed() {
+		return nil
+	}
+
+	switch ev.Key {
+	case input.KeyEscape:
+		s.Done = true
+	case input.KeyLeft, input.KeyA:
+		s.change(-1)
+	case input.KeyRight, input.KeyD:
+		s.change(+1)
+	default:
+		s.Menu.Key(ev)
+	}
+	return nil
+}
+
+// change steps the rule under the cursor, wrapping around at the ends.
+func (s *versusSetupScreen) change(step int) {
+	switch s.Menu.Cursor {
+	case versusRounds:
+		s.Rules.RoundsToWin = 1 + (s.Rules.RoundsToWin-1+step+maxRoundsToWin)%maxRoundsToWin
+	case versusLives:
+		s.Rules.Lives = 1 + (s.Rules.Lives-1+step+maxVersusLives)%maxVersusLives
+	case versusAsteroids:
+		s.Rules.Asteroids = (s.Rules.Asteroids + step + maxVersusRocks + 1) % (maxVersusRocks + 1)
+	case versusFriendlyFire:
+		s.Rules.FriendlyFire = !s.Rules.FriendlyFire
+	}
+}
+
+func (s *versusSetupScreen) Frame(ev pancake.FrameEvent) (screenOp, error) {
+	if choice, chosen := s.Menu.Choice(); chosen {
+		switch choice {
+		case versusStart:
+			s.NewMatch(time.Now().UnixNano(), maxPlayers, *s.Rules)
+			return replaceAll(newRoundStartScreen(s.Res, s.session)), nil
+		case versusBack:
+			s.Done = true
+		default:
+			s.change(+1)
+		}
+		s.print()
+	}
+
+	if s.Done {
+		return pop(), nil
+	}
+	return screenOp{}, nil
+}
+
+func (s *versusSetupScreen) Draw(ev pancake.DrawEvent) error {
+	s.Shader.Begin()
+	s.Drawer.Draw(s.Background)
+	s.Drawer.Draw(s.Text)
+	s.Shader.End()
+	return nil
+}
+
+func (s *versusSetupScreen) print() {
+	s.Menu.Items = []string{
+		versusRounds:       fmt.Sprintf("Rounds to win: %d", s.Rules.RoundsToWin),
+		versusLives:        fmt.Sprintf("Ships per round: %d", s.Rules.Lives),
+		versusAsteroids:    fmt.Sprintf("Asteroids: %d", s.Rules.Asteroids),
+		versusFriendlyFire: "Friendly fire: " + onOff(s.Rules.FriendlyFire),
+		versusStart:        "Start match",
+		versusBack:         "Back",
+	}
+	s.Text.Clear()
+	fmt.Fprintf(s.Text, "Versus\n\n")
+	s.Menu.Print(s.Text)
+	fmt.Fprintf(s.Text, "\nLeft and right change the rules.")
+}